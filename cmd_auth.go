@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// auth runs the OAuth flow once (mt3.GetClient caches the token in ~/.credentials)
+// and proves it worked by looking up my channel
+func runAuth(ctx context.Context, args []string) {
+	fs := newFlagSet("auth")
+	apiOpts := addAPIFlags(fs)
+	upload := fs.Bool("upload", false, "Ask for permission to upload videos instead of read only access")
+	fs.Parse(args)
+
+	// If modifying these scopes, delete your previously saved credentials
+	// at ~/.credentials/youtube-go.json
+	scope := youtube.YoutubeReadonlyScope
+	if *upload {
+		scope = youtube.YoutubeUploadScope
+	}
+	api := apiOpts.connect(ctx, scope)
+
+	response, err := api.ChannelsListMine(ctx, "snippet,contentDetails,statistics")
+	handleError(err, "")
+	if len(response.Items) == 0 {
+		log.Fatalf("Authorized, but this account has no YouTube channel")
+	}
+	channel := response.Items[0]
+	title := ""
+	if channel.Snippet != nil {
+		title = channel.Snippet.Title
+	}
+	var views uint64
+	if channel.Statistics != nil {
+		views = channel.Statistics.ViewCount
+	}
+	fmt.Println(fmt.Sprintf("This channel's ID is %s. Its title is '%s', "+
+		"and it has %d views.",
+		channel.Id,
+		title,
+		views))
+}
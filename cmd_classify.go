@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+// classify is a dry run of the rules file against every known video.
+// Nothing is saved unless --apply is given.
+func runClassify(ctx context.Context, args []string) {
+	fs := newFlagSet("classify")
+	storeOpts := addStoreFlags(fs)
+	rules := addRulesFlag(fs)
+	apply := fs.Bool("apply", false, "Save the new types to knownvideos.toml instead of only showing them")
+	changedOnly := fs.Bool("changed", false, "Only list videos whose type would change")
+	fs.Parse(args)
+
+	storePath := storeOpts.path()
+	knownVideos := loadKnownVideos(ctx, storePath)
+	before := knownVideos.Copy()
+	classifier := loadClassifier(*rules)
+
+	videoIDs := knownVideos.SortedVideoIds(nil)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Video\tPublished\tNow\tRules say\tRule\tTitle")
+	changed := 0
+	for _, videoID := range videoIDs {
+		video := knownVideos.Videos[videoID]
+		newType, ruleName := classifier.Classify(video)
+		if ruleName == "" {
+			ruleName = "(default)"
+		}
+		if forced := knownVideos.Overrides[videoID].VideoType; forced != "" {
+			ruleName += ", overridden to " + string(forced)
+		}
+		marker := ""
+		if newType != video.VideoType {
+			changed++
+			marker = " *"
+		} else if *changedOnly {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%v\t%v%s\t%s\t%s\n", video.VideoId, video.Published.Format("2006-01-02"), video.VideoType, newType, marker, ruleName, video.Title)
+		video.VideoType = newType
+		knownVideos.Videos[videoID] = video
+	}
+	w.Flush()
+
+	if !*apply {
+		fmt.Printf("%d of %d videos would change type (marked *).  Run with --apply to save them.\r\n", changed, len(videoIDs))
+		return
+	}
+	if changed == 0 {
+		fmt.Println("No video changed type, nothing to save")
+		return
+	}
+	saveKnownVideos(storeOpts, storePath, "classify", before, knownVideos)
+	fmt.Printf("Saved new types for %d of %d videos\r\n", changed, len(videoIDs))
+}
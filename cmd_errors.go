@@ -0,0 +1,28 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+
+	"github.com/marbletracks/go-get-video-durations/mt3"
+)
+
+// handleError exits the program if err is set.  Only the commands exit; mt3 returns errors.
+// Being interrupted (a cancelled context) exits with interruptedExitCode, like a shell does for Ctrl-C.
+func handleError(err error, message string) {
+	if message == "" {
+		message = "Error making API call"
+	}
+	if errors.Is(err, context.Canceled) {
+		log.Printf("%s: interrupted", message)
+		os.Exit(interruptedExitCode)
+	}
+	if mt3.IsAPIError(err, mt3.APIErrorQuotaExceeded) {
+		message += " (the daily quota resets at midnight Pacific time)"
+	}
+	if err != nil {
+		log.Fatalf(message+": %v", err.Error())
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/marbletracks/go-get-video-durations/mt3"
+)
+
+// export writes a Hugo content file for every known video
+func runExport(ctx context.Context, args []string) {
+	fs := newFlagSet("export")
+	storeOpts := addStoreFlags(fs)
+	contentDir := fs.String("content-dir", "", "Hugo content directory to write livestreams/ and snippets/ into.  Overrides hugo_content in config.toml")
+	fs.Parse(args)
+
+	dir, err := mt3.ResolveHugoContentDir(*contentDir)
+	if err != nil {
+		log.Fatalf("Unable to figure out where to export to: %v", err)
+	}
+	knownVideos := loadKnownVideos(ctx, storeOpts.path())
+
+	summary, err := mt3.ExportHugo(knownVideos, dir)
+	if err != nil {
+		log.Fatalf("Unable to export to %s: %v", dir, err)
+	}
+	fmt.Printf("Exported %d videos to %s: %v\r\n", len(knownVideos.Videos), dir, summary)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/marbletracks/go-get-video-durations/mt3"
+)
+
+// history shows the audit log: what each run of a command changed, for every video or just one
+func runHistory(ctx context.Context, args []string) {
+	fs := newFlagSet("history")
+	storeOpts := addStoreFlags(fs)
+	videoId := fs.String("video", "", "Only show changes to this video")
+	run := fs.String("run", "", "Only show changes made by this run (see --runs)")
+	field := fs.String("field", "", "Only show changes to this field, e.g. Title or Duration")
+	runs := fs.Bool("runs", false, "List the runs that changed something instead of the changes")
+	fs.Parse(args)
+
+	logPath := mt3.AuditLogPath(storeOpts.path())
+	entries, err := mt3.ReadAuditLog(logPath)
+	if err != nil {
+		log.Fatalf("Unable to read the audit log: %v", err)
+	}
+
+	var matching []mt3.AuditEntry
+	for _, entry := range entries {
+		if (*videoId == "" || entry.VideoId == *videoId) &&
+			(*run == "" || entry.Run == *run) &&
+			(*field == "" || entry.Field == *field) {
+			matching = append(matching, entry)
+		}
+	}
+	if len(matching) == 0 {
+		fmt.Printf("No changes in %s match\r\n", logPath)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if *runs {
+		printRuns(w, matching)
+	} else {
+		fmt.Fprintln(w, "Time\tRun\tCommand\tVideo\tField\tOld\tNew")
+		for _, entry := range matching {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", entry.Time.Local().Format("2006-01-02 15:04:05"), entry.Run, entry.Command,
+				entry.VideoId, entry.Field, shorten(entry.Old), shorten(entry.New))
+		}
+	}
+	w.Flush()
+}
+
+// printRuns prints one line per run with how many videos and fields it changed
+func printRuns(w *tabwriter.Writer, entries []mt3.AuditEntry) {
+	type runSummary struct {
+		first  mt3.AuditEntry
+		videos map[string]bool
+		fields int
+	}
+	var order []string
+	summaries := make(map[string]*runSummary)
+	for _, entry := range entries {
+		summary, ok := summaries[entry.Run]
+		if !ok {
+			summary = &runSummary{first: entry, videos: make(map[string]bool)}
+			summaries[entry.Run] = summary
+			order = append(order, entry.Run)
+		}
+		summary.videos[entry.VideoId] = true
+		summary.fields++
+	}
+	fmt.Fprintln(w, "Run\tTime\tCommand\tVideos\tChanges")
+	for _, run := range order {
+		summary := summaries[run]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", run, summary.first.Time.Local().Format("2006-01-02 15:04:05"), summary.first.Command, len(summary.videos), summary.fields)
+	}
+}
+
+// shorten keeps long descriptions from swamping the table
+func shorten(value string) string {
+	const limit = 60
+	runes := []rune(value)
+	if len(runes) <= limit {
+		return value
+	}
+	return string(runes[:limit-3]) + "..."
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/marbletracks/go-get-video-durations/mt3"
+)
+
+// migrate copies the catalog between stores, e.g. from knownvideos.toml to knownvideos.db
+func runMigrate(ctx context.Context, args []string) {
+	fs := newFlagSet("migrate")
+	storeOpts := addStoreFlags(fs)
+	to := fs.String("to", "", "Store to copy into; the extension picks the kind, e.g. knownvideos.db for SQLite")
+	force := fs.Bool("force", false, "Replace the --to store if it already exists (it is backed up first)")
+	fs.Parse(args)
+	if *to == "" {
+		fs.Usage()
+		log.Fatalf("Where to?  Give --to")
+	}
+
+	fromPath := storeOpts.path()
+	toPath, err := mt3.ResolveStorePath(*to)
+	if err != nil {
+		log.Fatalf("Unable to figure out where %s is: %v", *to, err)
+	}
+	if toPath == fromPath {
+		log.Fatalf("%s is already the store", toPath)
+	}
+	if _, err := os.Stat(toPath); err == nil && !*force {
+		log.Fatalf("%s already exists; use --force to replace it", toPath)
+	}
+
+	knownVideos, err := mt3.MigrateStore(ctx, mt3.OpenStore(fromPath), mt3.OpenStore(toPath), *storeOpts.keepBackups)
+	if err != nil {
+		log.Fatalf("Unable to migrate: %v", err)
+	}
+	fmt.Printf("Copied %d videos and %d overrides to %s and checked them.  Use --store=%s (or store in config.toml) to switch.\r\n",
+		len(knownVideos.Videos), len(knownVideos.Overrides), toPath, toPath)
+}
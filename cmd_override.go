@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/marbletracks/go-get-video-durations/mt3"
+)
+
+// override sets, clears or lists the manual overrides in knownvideos.toml.  Sync never changes them.
+func runOverride(ctx context.Context, args []string) {
+	if len(args) < 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s override set|clear|list ...\n", os.Args[0])
+		os.Exit(2)
+	}
+	switch args[0] {
+	case "set":
+		runOverrideSet(ctx, args[1:])
+	case "clear":
+		runOverrideClear(ctx, args[1:])
+	case "list":
+		runOverrideList(ctx, args[1:])
+	default:
+		log.Fatalf("Unknown override command %q, want set, clear or list", args[0])
+	}
+}
+
+// splitVideoId lets the video ID come before, after or among the flags
+func splitVideoId(fs *flag.FlagSet, args []string) string {
+	var videoId string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		videoId, args = args[0], args[1:]
+	}
+	fs.Parse(args)
+	if videoId == "" && fs.NArg() > 0 {
+		videoId = fs.Arg(0)
+		fs.Parse(fs.Args()[1:]) // flags after the ID too
+	}
+	if videoId == "" || fs.NArg() > 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	return videoId
+}
+
+// flagsGiven is the names of the flags on the command line, so only those fields are changed
+func flagsGiven(fs *flag.FlagSet) map[string]bool {
+	given := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	return given
+}
+
+func runOverrideSet(ctx context.Context, args []string) {
+	fs := newFlagSet("override set <videoId>")
+	storeOpts := addStoreFlags(fs)
+	title := fs.String("title", "", "Title to show instead of YouTube's")
+	videoType := fs.String("type", "", "Type to force, whatever the rules say, e.g. Livestream")
+	exclude := fs.Bool("exclude", false, "Leave the video out of report totals (--exclude=false to count it again)")
+	notes := fs.String("notes", "", "Anything worth remembering about the video")
+	videoId := splitVideoId(fs, args)
+	given := flagsGiven(fs)
+	if !given["title"] && !given["type"] && !given["exclude"] && !given["notes"] {
+		log.Fatalf("Nothing to set; give at least one of --title, --type, --exclude or --notes")
+	}
+
+	storePath := storeOpts.path()
+	knownVideos := loadKnownVideos(ctx, storePath)
+	before := knownVideos.Copy()
+
+	override := knownVideos.Overrides[videoId]
+	if given["title"] {
+		override.Title = *title
+	}
+	if given["type"] {
+		override.VideoType = mt3.MT3VideoType(*videoType)
+	}
+	if given["exclude"] {
+		override.Exclude = *exclude
+	}
+	if given["notes"] {
+		override.Notes = *notes
+	}
+	if err := knownVideos.SetOverride(videoId, override); err != nil {
+		log.Fatalf("Unable to set override: %v", err)
+	}
+	saveKnownVideos(storeOpts, storePath, "override set", before, knownVideos)
+	fmt.Printf("Override for %s: %s\r\n", videoId, describeOverride(override))
+}
+
+func runOverrideClear(ctx context.Context, args []string) {
+	fs := newFlagSet("override clear <videoId>")
+	storeOpts := addStoreFlags(fs)
+	fs.Bool("title", false, "Clear only the title")
+	fs.Bool("type", false, "Clear only the forced type")
+	fs.Bool("exclude", false, "Clear only the exclude flag")
+	fs.Bool("notes", false, "Clear only the notes")
+	videoId := splitVideoId(fs, args)
+	given := flagsGiven(fs)
+	all := !given["title"] && !given["type"] && !given["exclude"] && !given["notes"]
+
+	storePath := storeOpts.path()
+	knownVideos := loadKnownVideos(ctx, storePath)
+	before := knownVideos.Copy()
+
+	override, exists := knownVideos.Overrides[videoId]
+	if !exists {
+		fmt.Printf("%s has no override, nothing to clear\r\n", videoId)
+		return
+	}
+	if all {
+		override = mt3.VideoOverride{}
+	}
+	if given["title"] {
+		override.Title = ""
+	}
+	if given["type"] {
+		override.VideoType = ""
+	}
+	if given["exclude"] {
+		override.Exclude = false
+	}
+	if given["notes"] {
+		override.Notes = ""
+	}
+	if err := knownVideos.SetOverride(videoId, override); err != nil {
+		log.Fatalf("Unable to clear override: %v", err)
+	}
+	saveKnownVideos(storeOpts, storePath, "override clear", before, knownVideos)
+	if override.IsEmpty() {
+		fmt.Printf("Cleared the override for %s\r\n", videoId)
+	} else {
+		fmt.Printf("Override for %s is now: %s\r\n", videoId, describeOverride(override))
+	}
+}
+
+func runOverrideList(ctx context.Context, args []string) {
+	fs := newFlagSet("override list")
+	storeOpts := addStoreFlags(fs)
+	fs.Parse(args)
+
+	knownVideos := loadKnownVideos(ctx, storeOpts.path())
+
+	var videoIds []string
+	for videoId := range knownVideos.Overrides {
+		videoIds = append(videoIds, videoId)
+	}
+	sort.Strings(videoIds)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Video\tTitle\tType\tExcluded\tNotes\tYouTube title")
+	for _, videoId := range videoIds {
+		override := knownVideos.Overrides[videoId]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%v\t%s\t%s\n", videoId, override.Title, string(override.VideoType), override.Exclude, override.Notes, knownVideos.Videos[videoId].Title)
+	}
+	w.Flush()
+	fmt.Printf("%d overrides\r\n", len(videoIds))
+}
+
+// describeOverride lists only the fields that are set
+func describeOverride(override mt3.VideoOverride) string {
+	var fields []string
+	if override.Title != "" {
+		fields = append(fields, fmt.Sprintf("title %q", override.Title))
+	}
+	if override.VideoType != "" {
+		fields = append(fields, "type "+string(override.VideoType))
+	}
+	if override.Exclude {
+		fields = append(fields, "excluded from totals")
+	}
+	if override.Notes != "" {
+		fields = append(fields, fmt.Sprintf("notes %q", override.Notes))
+	}
+	return strings.Join(fields, ", ")
+}
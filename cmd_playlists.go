@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"google.golang.org/api/youtube/v3"
+
+	"github.com/marbletracks/go-get-video-durations/mt3"
+)
+
+func runPlaylists(ctx context.Context, args []string) {
+	fs := newFlagSet("playlists")
+	apiOpts := addAPIFlags(fs)
+	channelId := fs.String("channelId", "", "Retrieve playlists for this channel. Value is a YouTube channel ID.")
+	hl := fs.String("hl", "", "Retrieve localized resource metadata for the specified application language.")
+	maxResults := fs.Int64("maxResults", 5, "The maximum number of playlist resources to include in the API response.")
+	mine := fs.Bool("mine", false, "List playlists for authenticated user's channel. Default: false.")
+	onBehalfOfContentOwner := fs.String("onBehalfOfContentOwner", "", "Indicates that the request's auth credentials identify a user authorized to act on behalf of the specified content owner.")
+	pageToken := fs.String("pageToken", "", "Token that identifies a specific page in the result set that should be returned.")
+	part := fs.String("part", "snippet,contentDetails", "Comma-separated list of playlist resource parts that API response will include.")
+	playlistId := fs.String("playlistId", "", "Retrieve information about this playlist.")
+	fs.Parse(args)
+
+	if *channelId == "" && *mine == false && *playlistId == "" {
+		log.Fatalf("You must either set a value for the channelId or playlistId flag or set the mine flag to 'true'.")
+	}
+	api := apiOpts.connect(ctx, youtube.YoutubeReadonlyScope)
+
+	response, err := api.PlaylistsList(ctx, *part, mt3.PlaylistsQuery{
+		ChannelId:              *channelId,
+		Hl:                     *hl,
+		MaxResults:             *maxResults,
+		Mine:                   *mine,
+		OnBehalfOfContentOwner: *onBehalfOfContentOwner,
+		PageToken:              *pageToken,
+		PlaylistId:             *playlistId,
+	})
+	handleError(err, "")
+
+	for _, playlist := range response.Items {
+		playlistId := playlist.Id
+		playlistTitle := playlist.Snippet.Title
+
+		// Print the playlist ID and title for the playlist resource.
+		fmt.Println(playlistId, ": ", playlistTitle)
+	}
+}
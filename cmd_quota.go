@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/marbletracks/go-get-video-durations/mt3"
+)
+
+// quota shows what the quota ledger has charged today, call by call, against the budget
+func runQuota(ctx context.Context, args []string) {
+	fs := newFlagSet("quota")
+	budgetFlag := addQuotaBudgetFlag(fs)
+	days := fs.Int("days", 1, "Show this many days, today first")
+	fs.Parse(args)
+
+	ledger, budget := loadQuotaLedger(*budgetFlag)
+	now := time.Now()
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for i := 0; i < *days; i++ {
+		day := mt3.QuotaDay(now.Add(-24 * time.Hour * time.Duration(i)))
+		used := ledger.Used(day)
+		if i == 0 {
+			fmt.Fprintf(w, "%s\t%d of %d units used, %d left until midnight Pacific time\n", day, used, budget, budget-used)
+		} else {
+			fmt.Fprintf(w, "%s\t%d units used\n", day, used)
+		}
+		calls, usage := ledger.Usage(day)
+		for _, call := range calls {
+			fmt.Fprintf(w, "  %s\t%d calls\t%d units\n", call, usage[call].Calls, usage[call].Units)
+		}
+	}
+	w.Flush()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+
+	"github.com/marbletracks/go-get-video-durations/mt3"
+)
+
+// refresh asks YouTube again about videos we already have, not just the ones missing a duration
+func runRefresh(ctx context.Context, args []string) {
+	fs := newFlagSet("refresh")
+	storeOpts := addStoreFlags(fs)
+	apiOpts := addAPIFlags(fs)
+	rules := addRulesFlag(fs)
+	ids := fs.String("ids", "", "Comma separated video IDs to refresh instead of every known video")
+	videoType := fs.String("type", "", "Only refresh videos of this type, e.g. Livestream")
+	publishedAfter := fs.String("published-after", "", "Only refresh videos published on or after this date (YYYY-MM-DD)")
+	publishedBefore := fs.String("published-before", "", "Only refresh videos published before this date (YYYY-MM-DD)")
+	fs.Parse(args)
+
+	var filter mt3.RefreshFilter
+	if *ids != "" {
+		filter.VideoIds = strings.Split(*ids, ",")
+	}
+	filter.VideoType = mt3.MT3VideoType(*videoType)
+	filter.PublishedAfter = parseDateFlag("published-after", *publishedAfter)
+	filter.PublishedBefore = parseDateFlag("published-before", *publishedBefore)
+
+	storePath := storeOpts.path()
+	knownVideos := loadKnownVideos(ctx, storePath)
+	run := newStoreRun(storeOpts, storePath, "refresh", knownVideos.Copy())
+	for _, videoId := range filter.VideoIds {
+		if _, exists := knownVideos.Videos[videoId]; !exists {
+			log.Fatalf("No known video with ID %s; run sync to add new videos", videoId)
+		}
+	}
+	classifier := loadClassifier(*rules)
+	apiOpts.checkQuota("refresh", mt3.EstimateRefresh(knownVideos, filter))
+	api := apiOpts.connect(ctx, youtube.YoutubeReadonlyScope)
+
+	// saving after every batch keeps the ones we got through, as if the refresh had only been asked for those
+	summary, changes, err := mt3.RefreshVideos(ctx, api, &knownVideos, filter, classifier, func() error {
+		return run.save(knownVideos)
+	})
+	for _, change := range changes {
+		fmt.Printf("%v\r\n", change)
+	}
+	run.stopIfInterrupted(err, knownVideos)
+	handleError(err, "Unable to refresh videos (the batches before it are saved)")
+	fmt.Printf("Refresh finished: %v\r\n", summary)
+
+	if err := run.save(knownVideos); err != nil {
+		log.Fatalf("Unable to save known videos: %v", err)
+	}
+}
+
+// parseDateFlag reads a YYYY-MM-DD flag value; "" is the zero time
+func parseDateFlag(name string, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		log.Fatalf("Bad --%s %q, want YYYY-MM-DD", name, value)
+	}
+	return date
+}
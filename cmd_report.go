@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/marbletracks/go-get-video-durations/mt3"
+)
+
+// report says how long I have spent on Marble Track 3
+func runReport(ctx context.Context, args []string) {
+	fs := newFlagSet("report")
+	storeOpts := addStoreFlags(fs)
+	periodName := fs.String("period", "month", "Group videos by the day, week, month or year they were published")
+	format := fs.String("format", "table", "Output format: table, csv or json")
+	includeUnavailable := fs.Bool("include-unavailable", false, "Count videos that are now private or removed in the totals")
+	listUnavailable := fs.Bool("unavailable", false, "List the videos that are private or removed instead of totals")
+	fs.Parse(args)
+
+	period, err := mt3.ParseReportPeriod(*periodName)
+	if err != nil {
+		log.Fatalf("Bad --period: %v", err)
+	}
+	var write func(mt3.Report) error
+	var writeUnavailable func([]mt3.VideoMeta) error
+	switch *format {
+	case "table":
+		write, writeUnavailable = writeReportTable, writeUnavailableTable
+	case "csv":
+		write, writeUnavailable = writeReportCSV, writeUnavailableCSV
+	case "json":
+		write, writeUnavailable = writeReportJSON, writeUnavailableJSON
+	default:
+		log.Fatalf("Bad --format %q, want table, csv or json", *format)
+	}
+
+	// csv and json go to stdout for other programs, so say where the store is on stderr
+	storePath, err := mt3.ResolveStorePath(*storeOpts.store)
+	if err != nil {
+		log.Fatalf("Unable to figure out where knownvideos.toml is: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "Using known videos in %s\r\n", storePath)
+	knownVideos := loadKnownVideos(ctx, storePath)
+
+	if *listUnavailable {
+		if err := writeUnavailable(mt3.UnavailableVideos(knownVideos)); err != nil {
+			log.Fatalf("Unable to write report: %v", err)
+		}
+		return
+	}
+
+	report := mt3.BuildReport(knownVideos, period, *includeUnavailable)
+	if err := write(report); err != nil {
+		log.Fatalf("Unable to write report: %v", err)
+	}
+	if report.Unavailable > 0 && !*includeUnavailable {
+		fmt.Fprintf(os.Stderr, "%d videos are private or removed and not counted; see --unavailable\r\n", report.Unavailable)
+	}
+	if report.Excluded > 0 {
+		fmt.Fprintf(os.Stderr, "%d videos are excluded by overrides and not counted\r\n", report.Excluded)
+	}
+	if report.Total.NoDuration > 0 {
+		fmt.Fprintf(os.Stderr, "%d videos have no duration yet; run the durations command\r\n", report.Total.NoDuration)
+	}
+}
+
+func writeReportTable(report mt3.Report) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	section := func(title string, rows []mt3.ReportRow) {
+		fmt.Fprintf(w, "%s\tVideos\tTotal\tAverage\tNo duration\t\n", title)
+		for _, row := range rows {
+			fmt.Fprintf(w, "%s\t%d\t%v\t%v\t%d\t\n", row.Group, row.Videos, row.Total, row.Average.Round(time.Second), row.NoDuration)
+		}
+		fmt.Fprintln(w, "\t\t\t\t\t")
+	}
+	section("Type", report.ByType)
+	section("By "+report.Period.String(), report.ByPeriod)
+	section("Total", []mt3.ReportRow{report.Total})
+	return w.Flush()
+}
+
+// CSV and JSON durations are whole seconds so spreadsheets can add them up
+func writeReportCSV(report mt3.Report) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"grouping", "group", "videos", "total_seconds", "average_seconds", "no_duration"})
+	write := func(grouping string, rows []mt3.ReportRow) {
+		for _, row := range rows {
+			w.Write([]string{
+				grouping,
+				row.Group,
+				strconv.Itoa(row.Videos),
+				strconv.FormatInt(int64(row.Total/time.Second), 10),
+				strconv.FormatInt(int64(row.Average/time.Second), 10),
+				strconv.Itoa(row.NoDuration),
+			})
+		}
+	}
+	write("type", report.ByType)
+	write(report.Period.String(), report.ByPeriod)
+	write("total", []mt3.ReportRow{report.Total})
+	w.Flush()
+	return w.Error()
+}
+
+type jsonReportRow struct {
+	Group          string `json:"group"`
+	Videos         int    `json:"videos"`
+	TotalSeconds   int64  `json:"total_seconds"`
+	AverageSeconds int64  `json:"average_seconds"`
+	NoDuration     int    `json:"no_duration"`
+}
+
+func toJSONReportRows(rows []mt3.ReportRow) []jsonReportRow {
+	jsonRows := []jsonReportRow{}
+	for _, row := range rows {
+		jsonRows = append(jsonRows, jsonReportRow{
+			Group:          row.Group,
+			Videos:         row.Videos,
+			TotalSeconds:   int64(row.Total / time.Second),
+			AverageSeconds: int64(row.Average / time.Second),
+			NoDuration:     row.NoDuration,
+		})
+	}
+	return jsonRows
+}
+
+func writeReportJSON(report mt3.Report) error {
+	out := struct {
+		Period      string          `json:"period"`
+		Excluded    int             `json:"excluded"`
+		Unavailable int             `json:"unavailable"`
+		Total       jsonReportRow   `json:"total"`
+		ByType      []jsonReportRow `json:"by_type"`
+		ByPeriod    []jsonReportRow `json:"by_period"`
+	}{
+		Period:      report.Period.String(),
+		Excluded:    report.Excluded,
+		Unavailable: report.Unavailable,
+		Total:       toJSONReportRows([]mt3.ReportRow{report.Total})[0],
+		ByType:      toJSONReportRows(report.ByType),
+		ByPeriod:    toJSONReportRows(report.ByPeriod),
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
+
+// noticedDate is when we noticed a video went private or was removed, blank if we never saw it happen
+func noticedDate(video mt3.VideoMeta) string {
+	if video.AvailabilityNoticed.IsZero() {
+		return ""
+	}
+	return video.AvailabilityNoticed.Format("2006-01-02")
+}
+
+func writeUnavailableTable(videos []mt3.VideoMeta) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Video\tStatus\tNoticed\tPublished\tDuration\tTitle")
+	for _, video := range videos {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%v\t%s\n", video.VideoId, video.Availability, noticedDate(video), video.Published.Format("2006-01-02"), video.Duration, video.Title)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("%d videos are private or removed\r\n", len(videos))
+	return nil
+}
+
+func writeUnavailableCSV(videos []mt3.VideoMeta) error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{"video_id", "status", "noticed", "published", "duration_seconds", "title"})
+	for _, video := range videos {
+		w.Write([]string{
+			video.VideoId,
+			string(video.Availability),
+			noticedDate(video),
+			video.Published.Format("2006-01-02"),
+			strconv.FormatInt(int64(video.Duration/time.Second), 10),
+			video.Title,
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func writeUnavailableJSON(videos []mt3.VideoMeta) error {
+	type jsonVideo struct {
+		VideoId         string `json:"video_id"`
+		Status          string `json:"status"`
+		Noticed         string `json:"noticed,omitempty"`
+		Published       string `json:"published"`
+		DurationSeconds int64  `json:"duration_seconds"`
+		Title           string `json:"title"`
+	}
+	out := []jsonVideo{}
+	for _, video := range videos {
+		out = append(out, jsonVideo{
+			VideoId:         video.VideoId,
+			Status:          string(video.Availability),
+			Noticed:         noticedDate(video),
+			Published:       video.Published.Format("2006-01-02"),
+			DurationSeconds: int64(video.Duration / time.Second),
+			Title:           video.Title,
+		})
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(out)
+}
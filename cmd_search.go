@@ -1,40 +1,28 @@
 package main
 
 import (
-	"flag"
+	"context"
 	"fmt"
-	"log"
-	"net/http"
 
-	"google.golang.org/api/googleapi/transport"
-	"google.golang.org/api/youtube/v3"
-)
-
-var (
-	query      = flag.String("query", "Marble Track 3 construction", "Search term")
-	maxResults = flag.Int64("max-results", 25, "Max YouTube results")
+	"github.com/marbletracks/go-get-video-durations/mt3"
 )
 
 // create key at https://console.developers.google.com/apis/credentials
 const developerKey = "YOUR DEVELOPER KEY"
 
-func main() {
-	flag.Parse()
+func runSearch(ctx context.Context, args []string) {
+	fs := newFlagSet("search")
+	apiOpts := addAPIFlags(fs)
+	query := fs.String("query", "Marble Track 3 construction", "Search term")
+	maxResults := fs.Int64("max-results", 25, "Max YouTube results")
+	key := fs.String("developer-key", developerKey, "YouTube Data API key")
+	fs.Parse(args)
 
-	client := &http.Client{
-		Transport: &transport.APIKey{Key: developerKey},
-	}
-
-	service, err := youtube.New(client)
-	if err != nil {
-		log.Fatalf("Error creating new YouTube client: %v", err)
-	}
+	apiOpts.checkQuota("search", mt3.EstimateCall("search.list"))
+	api := apiOpts.connectWithKey(*key)
 
 	// Make the API call to YouTube.
-	call := service.Search.List("id,snippet").
-		Q(*query).
-		MaxResults(*maxResults)
-	response, err := call.Do()
+	response, err := api.SearchList(ctx, "id,snippet", *query, *maxResults)
 	handleError(err, "")
 
 	// Group video, channel, and playlist results in separate lists.
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/marbletracks/go-get-video-durations/mt3"
+)
+
+func runBackups(ctx context.Context, args []string) {
+	fs := newFlagSet("backups")
+	storeOpts := addStoreFlags(fs)
+	fs.Parse(args)
+
+	storePath := storeOpts.path()
+	backups, err := mt3.ListBackups(storePath)
+	if err != nil {
+		log.Fatalf("Unable to list backups: %v", err)
+	}
+	for _, backup := range backups {
+		fmt.Println(backup)
+	}
+}
+
+// restore takes "latest" or a timestamp from the backups command
+func runRestore(ctx context.Context, args []string) {
+	fs := newFlagSet("restore")
+	storeOpts := addStoreFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s [flags] latest|<timestamp from the backups command>\n", fs.Name())
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		log.Fatalf("Which backup?  Say latest or give a timestamp")
+	}
+
+	storePath := storeOpts.path()
+	backup, err := mt3.RestoreKnownVideos(storePath, fs.Arg(0), *storeOpts.keepBackups)
+	if err != nil {
+		log.Fatalf("Unable to restore: %v", err)
+	}
+	fmt.Printf("Restored %s from %s\r\n", storePath, backup)
+}
+
+func runRepair(ctx context.Context, args []string) {
+	fs := newFlagSet("repair")
+	storeOpts := addStoreFlags(fs)
+	fs.Parse(args)
+
+	storePath := storeOpts.path()
+	if _, ok := mt3.OpenStore(storePath).(*mt3.TOMLStore); !ok {
+		log.Fatalf("repair only knows how to salvage a TOML store; restore a backup of %s instead", storePath)
+	}
+	knownVideos, report, err := mt3.RepairKnownVideos(storePath)
+	if err != nil {
+		log.Fatalf("Unable to repair %s: %v", storePath, err)
+	}
+	for _, lost := range report.Lost {
+		fmt.Printf("Could not salvage %s\r\n", lost)
+	}
+	// the corrupt file is kept as a backup; knownVideos carries the salvaged overrides along with the videos
+	if err := mt3.SaveLocalKnownVideos(ctx, storePath, knownVideos, *storeOpts.keepBackups); err != nil {
+		log.Fatalf("Unable to save the salvaged videos: %v", err)
+	}
+	fmt.Printf("Salvaged %d videos and %d overrides, lost %d.  The original is in the backups command\r\n",
+		report.Salvaged, report.SalvagedOverrides, len(report.Lost))
+}
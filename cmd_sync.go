@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"google.golang.org/api/youtube/v3"
+
+	"github.com/marbletracks/go-get-video-durations/mt3"
+)
+
+// sync is what my_uploads.go used to do: find new uploads, fill in durations, save.
+// The store is saved after every page and every durations batch, so a sync that dies
+// part way keeps what it fetched, and the next one carries on from the page it got to.
+// Ctrl-C stops the call in flight and saves everything before it.
+func runSync(ctx context.Context, args []string) {
+	fs := newFlagSet("sync")
+	storeOpts := addStoreFlags(fs)
+	apiOpts := addAPIFlags(fs)
+	rules := addRulesFlag(fs)
+	full := fs.Bool("full", false, "Walk every page of the uploads playlist")
+	incremental := fs.Bool("incremental", false, "Only walk pages until they are older than the newest known video (default)")
+	restart := fs.Bool("restart", false, "Start from the first page even if the last sync did not finish")
+	fs.Parse(args)
+	if *full && *incremental {
+		log.Fatalf("--full and --incremental cannot be used together")
+	}
+
+	storePath := storeOpts.path()
+	knownVideos := loadKnownVideos(ctx, storePath)
+	run := newStoreRun(storeOpts, storePath, "sync", knownVideos.Copy())
+	classifier := loadClassifier(*rules)
+
+	resumePath := mt3.SyncResumePath(storePath)
+	resume, err := mt3.LoadSyncResume(resumePath)
+	if err != nil {
+		log.Fatalf("Unable to read where the last sync got to from %s: %v", resumePath, err)
+	}
+	if *restart {
+		resume = nil
+	}
+
+	options := mt3.SyncOptions{
+		FullSync: *full,
+		Resume:   resume,
+		Checkpoint: func(progress mt3.SyncResume) error {
+			if err := run.save(knownVideos); err != nil {
+				return err
+			}
+			return mt3.SaveSyncResume(resumePath, progress)
+		},
+	}
+	apiOpts.checkQuota("sync", mt3.EstimateSync(knownVideos, options))
+	api := apiOpts.connect(ctx, youtube.YoutubeReadonlyScope)
+	summary, err := mt3.LoadNewVideosFromMyChannel(ctx, api, &knownVideos, options, classifier) // send by reference because we will add new videos from Youtube
+	run.stopIfInterrupted(err, knownVideos)
+	handleError(err, "Unable to sync (every page before this one is saved; run sync again to carry on)")
+	fmt.Printf("Sync finished: %v\r\n", summary)
+
+	err = fillInDurations(ctx, api, &knownVideos, classifier, run) // send by reference so we can update the Durations
+	run.stopIfInterrupted(err, knownVideos)
+	handleError(err, "Unable to get durations (the ones already fetched are saved)")
+
+	if err := run.save(knownVideos); err != nil {
+		log.Fatalf("Unable to save known videos: %v", err)
+	}
+}
+
+// durations only fills in what is missing, without looking for new uploads
+func runDurations(ctx context.Context, args []string) {
+	fs := newFlagSet("durations")
+	storeOpts := addStoreFlags(fs)
+	apiOpts := addAPIFlags(fs)
+	rules := addRulesFlag(fs)
+	fs.Parse(args)
+
+	storePath := storeOpts.path()
+	knownVideos := loadKnownVideos(ctx, storePath)
+	run := newStoreRun(storeOpts, storePath, "durations", knownVideos.Copy())
+	classifier := loadClassifier(*rules)
+	apiOpts.checkQuota("durations", mt3.EstimateDurations(knownVideos))
+	api := apiOpts.connect(ctx, youtube.YoutubeReadonlyScope)
+
+	err := fillInDurations(ctx, api, &knownVideos, classifier, run)
+	run.stopIfInterrupted(err, knownVideos)
+	handleError(err, "Unable to get durations (the ones already fetched are saved)")
+
+	if err := run.save(knownVideos); err != nil {
+		log.Fatalf("Unable to save known videos: %v", err)
+	}
+}
+
+// fillInDurations is mt3.FillInDurations saving the store after every batch
+func fillInDurations(ctx context.Context, api mt3.YouTubeAPI, knownVideos *mt3.KnownVideos, classifier *mt3.Classifier, run *storeRun) error {
+	return mt3.FillInDurations(ctx, api, knownVideos, classifier, func() error {
+		return run.save(*knownVideos)
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"google.golang.org/api/youtube/v3"
+
+	"github.com/marbletracks/go-get-video-durations/mt3"
+)
+
+func runUpload(ctx context.Context, args []string) {
+	fs := newFlagSet("upload")
+	apiOpts := addAPIFlags(fs)
+	filename := fs.String("filename", "", "Name of video file to upload")
+	title := fs.String("title", "Test Title", "Video title")
+	description := fs.String("description", "Test Description", "Video description")
+	category := fs.String("category", "22", "Video category")
+	keywords := fs.String("keywords", "", "Comma separated list of video keywords")
+	privacy := fs.String("privacy", "unlisted", "Video privacy status")
+	fs.Parse(args)
+
+	if *filename == "" {
+		log.Fatalf("You must provide a filename of a video file to upload")
+	}
+
+	apiOpts.checkQuota("upload", mt3.EstimateCall("videos.insert"))
+	api := apiOpts.connect(ctx, youtube.YoutubeUploadScope)
+
+	upload := &youtube.Video{
+		Snippet: &youtube.VideoSnippet{
+			Title:       *title,
+			Description: *description,
+			CategoryId:  *category,
+		},
+		Status: &youtube.VideoStatus{PrivacyStatus: *privacy},
+	}
+
+	// The API returns a 400 Bad Request response if tags is an empty string.
+	if strings.Trim(*keywords, "") != "" {
+		upload.Snippet.Tags = strings.Split(*keywords, ",")
+	}
+
+	file, err := os.Open(*filename)
+	if err != nil {
+		log.Fatalf("Error opening %v: %v", *filename, err)
+	}
+	defer file.Close()
+
+	response, err := api.VideosInsert(ctx, "snippet,status", upload, file)
+	handleError(err, "")
+	fmt.Printf("Upload successful! Video ID: %v\n", response.Id)
+}
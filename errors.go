@@ -1,20 +0,0 @@
-package main
-
-import (
-  "log"
-)
-
-func check(e error) {
-    if e != nil {
-        panic(e)
-    }
-}
-
-func handleError(err error, message string) {
-  if message == "" {
-    message = "Error making API call"
-  }
-  if err != nil {
-    log.Fatalf(message + ": %v", err.Error())
-  }
-}
@@ -0,0 +1,312 @@
+// go-get-video-durations keeps track of every video on the Marble Track 3 YouTube channel
+// and how long they are.  Run it with no arguments to see the commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/marbletracks/go-get-video-durations/mt3"
+)
+
+// command is one subcommand, e.g. "sync".  run gets the arguments after the command name,
+// and a ctx that is cancelled by Ctrl-C or SIGTERM (see interruptContext).
+type command struct {
+	summary string
+	run     func(ctx context.Context, args []string)
+}
+
+var commands = map[string]command{
+	"sync":      {"Add new videos from my channel to knownvideos.toml and fill in their durations", runSync},
+	"durations": {"Fill in durations for known videos that do not have one yet", runDurations},
+	"refresh":   {"Ask YouTube again about known videos and record what changed, including deleted and private ones", runRefresh},
+	"report":    {"Print how many videos there are and how long they are", runReport},
+	"export":    {"Write a Hugo content file for every known video", runExport},
+	"classify":  {"Show which rule classifies each known video, and optionally save the new types", runClassify},
+	"override":  {"Set, clear or list manual titles, types, exclusions and notes that sync leaves alone", runOverride},
+	"history":   {"Show what each run changed, per video or per run", runHistory},
+	"migrate":   {"Copy the known videos from one store to another, e.g. TOML to SQLite", runMigrate},
+	"backups":   {"List the backups of knownvideos.toml", runBackups},
+	"restore":   {"Roll knownvideos.toml back to a backup", runRestore},
+	"repair":    {"Salvage every video and override that can still be parsed from a corrupt knownvideos.toml", runRepair},
+	"playlists": {"List playlists for a channel, for my channel, or by ID", runPlaylists},
+	"search":    {"Search YouTube by keyword", runSearch},
+	"upload":    {"Upload a video to my channel", runUpload},
+	"auth":      {"Authorize with YouTube and cache the OAuth token", runAuth},
+	"quota":     {"Show how much of today's YouTube API quota budget has been used", runQuota},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> --help' for the flags of each command.\n", os.Args[0])
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		if os.Args[1] != "help" && os.Args[1] != "--help" && os.Args[1] != "-h" {
+			fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", os.Args[1])
+		}
+		usage()
+		os.Exit(2)
+	}
+	cmd.run(interruptContext(), os.Args[2:])
+}
+
+// interruptContext is cancelled by the first Ctrl-C or SIGTERM, so the command can stop the call
+// in flight and save what it has.  After that the signals go back to their defaults,
+// so a second Ctrl-C quits straight away.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		signal.Stop(signals)
+		fmt.Fprintf(os.Stderr, "\r\nGot %v, stopping once what we have is saved (Ctrl-C again to quit now)\r\n", sig)
+		cancel()
+	}()
+	return ctx
+}
+
+// interruptedExitCode is what a shell reports for a command killed by Ctrl-C
+const interruptedExitCode = 130
+
+// newFlagSet makes the flag set for a command; parse errors exit like the flag package does by default
+func newFlagSet(name string) *flag.FlagSet {
+	return flag.NewFlagSet(os.Args[0]+" "+name, flag.ExitOnError)
+}
+
+// storeOptions are the flags of every command that reads or writes knownvideos.toml
+type storeOptions struct {
+	store       *string
+	keepBackups *int
+}
+
+func addStoreFlags(fs *flag.FlagSet) storeOptions {
+	return storeOptions{
+		store:       fs.String("store", "", "Path to knownvideos.toml, or a .db/.sqlite file to use SQLite.  Overrides $"+mt3.StoreEnvVar+" and store in config.toml"),
+		keepBackups: fs.Int("keep-backups", 10, "How many timestamped backups of knownvideos.toml to keep next to it.  0 keeps them all"),
+	}
+}
+
+// path works out where knownvideos.toml is, exiting if we cannot tell
+func (options storeOptions) path() string {
+	storePath, err := mt3.ResolveStorePath(*options.store)
+	if err != nil {
+		log.Fatalf("Unable to figure out where knownvideos.toml is: %v", err)
+	}
+	fmt.Printf("Using known videos in %s\r\n", storePath)
+	return storePath
+}
+
+// loadKnownVideos reads the store (see mt3.OpenStore), refusing to carry on if it is corrupt
+// or was written by a newer build
+func loadKnownVideos(ctx context.Context, storePath string) mt3.KnownVideos {
+	knownVideos, err := mt3.OpenStore(storePath).Load(ctx)
+	if errors.Is(err, context.Canceled) {
+		os.Exit(interruptedExitCode)
+	}
+	var schemaErr *mt3.StoreSchemaError
+	if errors.As(err, &schemaErr) {
+		log.Fatalf("Refusing to continue: %v\r\nUpgrade go-get-video-durations before using this store", err)
+	}
+	if err != nil {
+		log.Fatalf("Refusing to continue: %v\r\nFix the file, run the repair command, or restore latest", err)
+	}
+	return knownVideos
+}
+
+func addRulesFlag(fs *flag.FlagSet) *string {
+	return fs.String("rules", "", "Rules file that decides each video's type.  Overrides rules in config.toml and rules.toml next to it")
+}
+
+// loadClassifier reads the rules file, or uses the built in rules if there is none
+func loadClassifier(rulesFlag string) *mt3.Classifier {
+	rulesPath, err := mt3.ResolveRulesPath(rulesFlag)
+	if err != nil {
+		log.Fatalf("Unable to figure out where the rules file is: %v", err)
+	}
+	classifier, err := mt3.LoadClassifier(rulesPath)
+	if err != nil {
+		log.Fatalf("Unable to load classification rules: %v", err)
+	}
+	if rulesPath != "" {
+		fmt.Printf("Classifying videos with rules in %s\r\n", rulesPath)
+	}
+	return classifier
+}
+
+// saveKnownVideos saves knownVideos and appends what changed since before to the audit log.
+// command is how the history command will describe this run, e.g. "override set".
+func saveKnownVideos(options storeOptions, storePath string, command string, before mt3.KnownVideos, knownVideos mt3.KnownVideos) {
+	run := newStoreRun(options, storePath, command, before)
+	if err := run.save(knownVideos); err != nil {
+		log.Fatalf("Unable to save known videos: %v", err)
+	}
+}
+
+// storeRun is one run of a command that saves the store, maybe several times part way through
+// so a failure only loses what came after the last save.
+// Its saves are not cancelled by Ctrl-C: flushing what we have is the whole point of stopping gracefully.
+// Every save appends what changed since the one before to the audit log, all under the same run ID,
+// and only the first that changes something makes a backup, so checkpoints and runs that
+// found nothing new do not push the real backups out.
+type storeRun struct {
+	options   storeOptions
+	storePath string
+	command   string
+	runId     string
+	saved     mt3.KnownVideos // what is in the store now
+	backedUp  bool
+}
+
+// newStoreRun starts a run on a store that holds before
+func newStoreRun(options storeOptions, storePath string, command string, before mt3.KnownVideos) *storeRun {
+	return &storeRun{options: options, storePath: storePath, command: command, runId: mt3.NewRunId(), saved: before}
+}
+
+func (run *storeRun) save(knownVideos mt3.KnownVideos) error {
+	changes := mt3.DiffKnownVideos(run.saved, knownVideos)
+	if len(changes) == 0 {
+		return nil // a run that changed nothing must not take a backup, or it pushes a real one out
+	}
+	keepBackups := *run.options.keepBackups
+	if run.backedUp {
+		keepBackups = mt3.SkipBackup
+	}
+	if err := mt3.OpenStore(run.storePath).Save(context.Background(), knownVideos, keepBackups); err != nil {
+		return err
+	}
+	run.backedUp = true
+	run.saved = knownVideos.Copy()
+
+	if err := mt3.AppendAuditLog(mt3.AuditLogPath(run.storePath), run.runId, run.command, changes); err != nil {
+		// the store is already saved, so losing some history is not worth stopping for
+		log.Printf("Unable to write the audit log: %v", err)
+	}
+	return nil
+}
+
+// stopIfInterrupted saves knownVideos and exits if err is because we were interrupted.
+// Any other err is left for the caller.
+func (run *storeRun) stopIfInterrupted(err error, knownVideos mt3.KnownVideos) {
+	if !errors.Is(err, context.Canceled) {
+		return
+	}
+	if err := run.save(knownVideos); err != nil {
+		log.Fatalf("Interrupted, and unable to save what we had: %v", err)
+	}
+	fmt.Printf("Interrupted; everything fetched so far is saved in %s\r\n", run.storePath)
+	os.Exit(interruptedExitCode)
+}
+
+// apiOptions are the flags of every command that talks to YouTube
+type apiOptions struct {
+	fakeAPI     *string
+	retries     *int
+	quotaBudget *int
+}
+
+func addAPIFlags(fs *flag.FlagSet) apiOptions {
+	return apiOptions{
+		fakeAPI:     fs.String("fake-api", "", "Directory of fixture JSON files to serve from a local fake YouTube API instead of the real one, e.g. testdata/fakeyoutube"),
+		retries:     fs.Int("retries", mt3.DefaultRetryPolicy.MaxAttempts-1, "How many times to retry an API call that fails with a 5xx, 429 or rate limit error, backing off exponentially"),
+		quotaBudget: addQuotaBudgetFlag(fs),
+	}
+}
+
+func addQuotaBudgetFlag(fs *flag.FlagSet) *int {
+	return fs.Int("quota-budget", 0, fmt.Sprintf("Quota units a day we allow ourselves; calls that would go over are refused.  Overrides quota_budget in config.toml (default %d)", mt3.DefaultQuotaBudget))
+}
+
+// retrying wraps api so every call is charged to the quota ledger (unless it is the fake),
+// temporary failures are retried, and every error is an *mt3.APIError
+func (options apiOptions) retrying(api mt3.YouTubeAPI) mt3.YouTubeAPI {
+	if *options.fakeAPI == "" {
+		ledger, budget := loadQuotaLedger(*options.quotaBudget)
+		api = mt3.NewQuotaYouTube(api, ledger, budget)
+	}
+	policy := mt3.DefaultRetryPolicy
+	policy.MaxAttempts = *options.retries + 1
+	return mt3.NewRetryingYouTube(api, policy)
+}
+
+// checkQuota refuses to start command if even the least it can cost does not fit in what is left
+// of today's budget, so it does not get part way and stop.  The fake API costs nothing.
+func (options apiOptions) checkQuota(command string, estimate mt3.QuotaEstimate) {
+	if *options.fakeAPI != "" {
+		return
+	}
+	ledger, budget := loadQuotaLedger(*options.quotaBudget)
+	if err := ledger.CheckEstimate(time.Now(), command, estimate, budget); err != nil {
+		log.Fatalf("Not starting: %v (the daily quota resets at midnight Pacific time)", err)
+	}
+}
+
+// loadQuotaLedger reads the quota ledger and works out today's budget
+func loadQuotaLedger(budgetFlag int) (*mt3.QuotaLedger, int) {
+	budget, err := mt3.ResolveQuotaBudget(budgetFlag)
+	if err != nil {
+		log.Fatalf("Unable to figure out the quota budget: %v", err)
+	}
+	ledgerPath, err := mt3.QuotaLedgerPath()
+	if err != nil {
+		log.Fatalf("Unable to figure out where the quota ledger is: %v", err)
+	}
+	ledger, err := mt3.LoadQuotaLedger(ledgerPath)
+	if err != nil {
+		log.Fatalf("Unable to load the quota ledger: %v", err)
+	}
+	return ledger, budget
+}
+
+// connect returns the real API authorized for scope, or the fake one if --fake-api was given
+func (options apiOptions) connect(ctx context.Context, scope string) mt3.YouTubeAPI {
+	var api *mt3.YouTubeService
+	var err error
+	if *options.fakeAPI != "" {
+		api, err = mt3.NewFakeYouTubeService(*options.fakeAPI)
+	} else {
+		api, err = mt3.NewYouTubeService(ctx, scope)
+	}
+	if err != nil {
+		log.Fatalf("Error creating YouTube client: %v", err)
+	}
+	return options.retrying(api)
+}
+
+// connectWithKey is connect for commands that only need an API key
+func (options apiOptions) connectWithKey(developerKey string) mt3.YouTubeAPI {
+	var api *mt3.YouTubeService
+	var err error
+	if *options.fakeAPI != "" {
+		api, err = mt3.NewFakeYouTubeService(*options.fakeAPI)
+	} else {
+		api, err = mt3.NewYouTubeServiceWithKey(developerKey)
+	}
+	if err != nil {
+		log.Fatalf("Error creating new YouTube client: %v", err)
+	}
+	return options.retrying(api)
+}
@@ -0,0 +1,165 @@
+package mt3
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/googleapi/transport"
+	"google.golang.org/api/option"
+	"google.golang.org/api/youtube/v3"
+)
+
+// videos.list accepts at most 50 IDs per call
+const MaxIdsPerVideosList = 50
+
+// YouTubeAPI is every YouTube Data API call we make.
+// YouTubeService talks to the real thing; FakeYouTube (fake_api.go) keeps everything in memory
+// so the sync logic in sync.go can be exercised without a network or credentials.
+// Errors are returned, not handled, so the caller decides whether one is fatal.
+// Every call takes a ctx, and gives up with ctx.Err() (wrapped) once it is cancelled.
+type YouTubeAPI interface {
+	ChannelsListMine(ctx context.Context, part string) (*youtube.ChannelListResponse, error)
+	PlaylistItemsList(ctx context.Context, part string, playlistId string, pageToken string, numItems int64) (*youtube.PlaylistItemListResponse, error)
+	VideosListMultipleIds(ctx context.Context, part string, id string) (*youtube.VideoListResponse, error)
+	PlaylistsList(ctx context.Context, part string, query PlaylistsQuery) (*youtube.PlaylistListResponse, error)
+	SearchList(ctx context.Context, part string, query string, maxResults int64) (*youtube.SearchListResponse, error)
+	VideosInsert(ctx context.Context, part string, video *youtube.Video, media io.Reader) (*youtube.Video, error)
+}
+
+// PlaylistsQuery holds the optional parameters of playlists.list.  Empty fields are not sent.
+type PlaylistsQuery struct {
+	ChannelId              string
+	Hl                     string
+	MaxResults             int64
+	Mine                   bool
+	OnBehalfOfContentOwner string
+	PageToken              string
+	PlaylistId             string
+}
+
+// parts splits a comma separated part, e.g. "snippet,contentDetails", the way the client library wants it
+func parts(part string) []string {
+	return strings.Split(part, ",")
+}
+
+// YouTubeService is the production YouTubeAPI, a thin wrapper around youtube.Service
+type YouTubeService struct {
+	service *youtube.Service
+}
+
+// NewYouTubeService authorizes with GetClient (auth.go) for the given scope.
+// ctx is only for the authorization; each call takes its own.
+func NewYouTubeService(ctx context.Context, scope string) (*YouTubeService, error) {
+	client, err := GetClient(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+	service, err := youtube.New(client)
+	if err != nil {
+		return nil, err
+	}
+	return &YouTubeService{service: service}, nil
+}
+
+// NewYouTubeServiceWithKey uses an API key instead of OAuth, which is enough for public data like search
+func NewYouTubeServiceWithKey(developerKey string) (*YouTubeService, error) {
+	client := &http.Client{
+		Transport: &transport.APIKey{Key: developerKey},
+	}
+	service, err := youtube.New(client)
+	if err != nil {
+		return nil, err
+	}
+	return &YouTubeService{service: service}, nil
+}
+
+// NewFakeYouTubeService starts FakeYouTubeServer on the fixtures and points youtube.Service at it.
+// The server runs until the program exits.
+func NewFakeYouTubeService(fixtureDir string) (*YouTubeService, error) {
+	fake, err := StartFakeYouTubeServer(fixtureDir)
+	if err != nil {
+		return nil, err
+	}
+	service, err := youtube.NewService(context.Background(),
+		option.WithEndpoint(fake.Endpoint()),
+		option.WithHTTPClient(fake.Client()))
+	if err != nil {
+		return nil, err
+	}
+	return &YouTubeService{service: service}, nil
+}
+
+// from https://developers.google.com/youtube/v3/docs/videos/list
+// Used ONLY to get the Durations of videos because https://issuetracker.google.com/issues/35170788
+// Thanks https://stackoverflow.com/questions/15596753/youtube-api-v3-how-to-get-video-durations
+func (yt *YouTubeService) VideosListMultipleIds(ctx context.Context, part string, id string) (*youtube.VideoListResponse, error) {
+	call := yt.service.Videos.List(parts(part))
+	if id != "" {
+		call = call.Id(id)
+	}
+	return call.Context(ctx).Do()
+}
+
+// Retrieve playlistItems in the specified playlist
+// This does not reliably returns the items sorted by published date.  (it is close, but not perfect)
+// If they were returned in sorted order, I could skip calling next page when I started getting hits on knownVideos
+// Incorrect sort might be related to https://issuetracker.google.com/issues/35176658
+func (yt *YouTubeService) PlaylistItemsList(ctx context.Context, part string, playlistId string, pageToken string, numItems int64) (*youtube.PlaylistItemListResponse, error) {
+	call := yt.service.PlaylistItems.List(parts(part))
+	call = call.MaxResults(numItems) // Hopefully speed things overall by requiring fewer calls  (default 5, max 50)
+	call = call.PlaylistId(playlistId)
+	if pageToken != "" {
+		call = call.PageToken(pageToken)
+	}
+	return call.Context(ctx).Do()
+}
+
+// Retrieve resource for the authenticated user's channel
+func (yt *YouTubeService) ChannelsListMine(ctx context.Context, part string) (*youtube.ChannelListResponse, error) {
+	call := yt.service.Channels.List(parts(part))
+	call = call.Mine(true)
+	return call.Context(ctx).Do()
+}
+
+// Retrieve playlists for a channel, for the authenticated user, or by ID
+func (yt *YouTubeService) PlaylistsList(ctx context.Context, part string, query PlaylistsQuery) (*youtube.PlaylistListResponse, error) {
+	call := yt.service.Playlists.List(parts(part))
+	if query.ChannelId != "" {
+		call = call.ChannelId(query.ChannelId)
+	}
+	if query.Hl != "" {
+		call = call.Hl(query.Hl)
+	}
+	if query.MaxResults != 0 {
+		call = call.MaxResults(query.MaxResults)
+	}
+	if query.Mine {
+		call = call.Mine(true)
+	}
+	if query.OnBehalfOfContentOwner != "" {
+		call = call.OnBehalfOfContentOwner(query.OnBehalfOfContentOwner)
+	}
+	if query.PageToken != "" {
+		call = call.PageToken(query.PageToken)
+	}
+	if query.PlaylistId != "" {
+		call = call.Id(query.PlaylistId)
+	}
+	return call.Context(ctx).Do()
+}
+
+// Search for videos, channels and playlists.  This costs 100 quota units, so go easy.
+func (yt *YouTubeService) SearchList(ctx context.Context, part string, query string, maxResults int64) (*youtube.SearchListResponse, error) {
+	call := yt.service.Search.List(parts(part))
+	call = call.Q(query)
+	call = call.MaxResults(maxResults)
+	return call.Context(ctx).Do()
+}
+
+// Upload media as a new video described by video
+func (yt *YouTubeService) VideosInsert(ctx context.Context, part string, video *youtube.Video, media io.Reader) (*youtube.Video, error) {
+	call := yt.service.Videos.Insert(parts(part), video)
+	return call.Media(media).Context(ctx).Do()
+}
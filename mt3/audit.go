@@ -0,0 +1,171 @@
+package mt3
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// One line of the audit log: a single field of a single video that one run of a command changed
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Run     string    `json:"run"` // every change saved by one run of a command has the same Run
+	Command string    `json:"command"`
+	VideoId string    `json:"video_id"`
+	Field   string    `json:"field"`
+	Old     string    `json:"old"`
+	New     string    `json:"new"`
+}
+
+// AuditLogPath is where changes to storePath are logged: knownvideos.toml gets knownvideos.audit.jsonl next to it
+func AuditLogPath(storePath string) string {
+	return strings.TrimSuffix(storePath, filepath.Ext(storePath)) + ".audit.jsonl"
+}
+
+// NewRunId names one run of a command in the audit log
+func NewRunId() string {
+	return time.Now().UTC().Format(backupTimeFormat)
+}
+
+// Copy returns a copy of knownVideos that later changes to knownVideos do not show up in,
+// so DiffKnownVideos can compare before and after
+func (knownVideos KnownVideos) Copy() KnownVideos {
+	var copied KnownVideos
+	if knownVideos.Videos != nil {
+		copied.Videos = make(map[string]VideoMeta, len(knownVideos.Videos))
+		for videoId, video := range knownVideos.Videos {
+			copied.Videos[videoId] = video
+		}
+	}
+	if knownVideos.Overrides != nil {
+		copied.Overrides = make(map[string]VideoOverride, len(knownVideos.Overrides))
+		for videoId, override := range knownVideos.Overrides {
+			copied.Overrides[videoId] = override
+		}
+	}
+	return copied
+}
+
+// diffOverride lists the override fields that differ, as Override.Title and so on
+func diffOverride(videoId string, before VideoOverride, after VideoOverride) []VideoChange {
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"Override.Title", before.Title, after.Title},
+		{"Override.VideoType", string(before.VideoType), string(after.VideoType)},
+		{"Override.Exclude", fmt.Sprint(before.Exclude), fmt.Sprint(after.Exclude)},
+		{"Override.Notes", before.Notes, after.Notes},
+	}
+	var changes []VideoChange
+	for _, field := range fields {
+		if field.old != field.new {
+			changes = append(changes, VideoChange{VideoId: videoId, Field: field.name, Old: field.old, New: field.new})
+		}
+	}
+	return changes
+}
+
+// DiffKnownVideos lists every change from before to after, sorted by video ID.
+// A video that is new or gone shows up as a change to the Video field.
+func DiffKnownVideos(before KnownVideos, after KnownVideos) []VideoChange {
+	videoIds := make(map[string]bool)
+	for videoId := range before.Videos {
+		videoIds[videoId] = true
+	}
+	for videoId := range after.Videos {
+		videoIds[videoId] = true
+	}
+	for videoId := range before.Overrides {
+		videoIds[videoId] = true
+	}
+	for videoId := range after.Overrides {
+		videoIds[videoId] = true
+	}
+	var sortedIds []string
+	for videoId := range videoIds {
+		sortedIds = append(sortedIds, videoId)
+	}
+	sort.Strings(sortedIds)
+
+	var changes []VideoChange
+	for _, videoId := range sortedIds {
+		oldVideo, hadVideo := before.Videos[videoId]
+		newVideo, hasVideo := after.Videos[videoId]
+		switch {
+		case !hadVideo && hasVideo:
+			changes = append(changes, VideoChange{VideoId: videoId, Field: "Video", Old: "", New: "added"})
+			changes = append(changes, diffVideoMeta(VideoMeta{VideoId: videoId}, newVideo)...)
+		case hadVideo && !hasVideo:
+			changes = append(changes, VideoChange{VideoId: videoId, Field: "Video", Old: "known", New: "dropped"})
+		case hadVideo && hasVideo:
+			changes = append(changes, diffVideoMeta(oldVideo, newVideo)...)
+		}
+		changes = append(changes, diffOverride(videoId, before.Overrides[videoId], after.Overrides[videoId])...)
+	}
+	return changes
+}
+
+// AppendAuditLog adds changes to the audit log at path, one JSON object per line, all stamped with run and command
+func AppendAuditLog(path string, run string, command string, changes []VideoChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for _, change := range changes {
+		entry := AuditEntry{Time: now, Run: run, Command: command, VideoId: change.VideoId, Field: change.Field, Old: change.Old, New: change.New}
+		if err := encoder.Encode(entry); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ReadAuditLog returns every entry in the audit log at path, oldest first.  A missing log has no entries.
+func ReadAuditLog(path string) ([]AuditEntry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // descriptions can be long
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return entries, fmt.Errorf("%s line %d: %w", path, lineNumber, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
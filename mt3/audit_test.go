@@ -0,0 +1,92 @@
+package mt3
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffKnownVideos(t *testing.T) {
+	before := KnownVideos{
+		Videos: map[string]VideoMeta{
+			"kept":    {VideoId: "kept", Title: "Old title", Duration: time.Minute},
+			"dropped": {VideoId: "dropped", Title: "Gone"},
+		},
+		Overrides: map[string]VideoOverride{"kept": {Notes: "check the title"}},
+	}
+	after := before.Copy()
+	video := after.Videos["kept"]
+	video.Title = "New title"
+	after.Videos["kept"] = video
+	delete(after.Videos, "dropped")
+	after.Videos["added"] = VideoMeta{VideoId: "added", Title: "Brand new"}
+	after.Overrides["kept"] = VideoOverride{Notes: "check the title", Exclude: true}
+	after.Overrides["added"] = VideoOverride{VideoType: Livestream}
+
+	if before.Videos["kept"].Title != "Old title" || before.Overrides["kept"].Exclude {
+		t.Fatal("changing the copy changed the original")
+	}
+
+	var got []string
+	for _, change := range DiffKnownVideos(before, after) {
+		got = append(got, change.String())
+	}
+	want := []string{
+		`added Video: "" -> "added"`,
+		`added Title: "" -> "Brand new"`,
+		`added Override.VideoType: "" -> "Livestream"`,
+		`dropped Video: "known" -> "dropped"`,
+		`kept Title: "Old title" -> "New title"`,
+		`kept Override.Exclude: "false" -> "true"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("changes:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	if changes := DiffKnownVideos(after, after.Copy()); len(changes) != 0 {
+		t.Errorf("a copy differs: %v", changes)
+	}
+}
+
+func TestAuditLogRoundTrip(t *testing.T) {
+	path := AuditLogPath(filepath.Join(t.TempDir(), "knownvideos.toml"))
+	if filepath.Base(path) != "knownvideos.audit.jsonl" {
+		t.Errorf("AuditLogPath = %s", path)
+	}
+	if entries, err := ReadAuditLog(path); err != nil || len(entries) != 0 {
+		t.Errorf("a log that does not exist yet = %v, %v; want no entries", entries, err)
+	}
+
+	first := []VideoChange{
+		{VideoId: "vid001", Field: "Title", Old: "Old", New: "New"},
+		{VideoId: "vid002", Field: "Description", Old: "", New: strings.Repeat("long ", 20000)},
+	}
+	second := []VideoChange{{VideoId: "vid001", Field: "Override.Exclude", Old: "false", New: "true"}}
+	if err := AppendAuditLog(path, "run1", "sync", first); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendAuditLog(path, "run2", "override", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendAuditLog(path, "run3", "override", second); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ReadAuditLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, fmt.Sprintf("%s %s %s %s %d", entry.Run, entry.Command, entry.VideoId, entry.Field, len(entry.New)))
+		if entry.Time.IsZero() {
+			t.Errorf("%s %s has no time", entry.Run, entry.Field)
+		}
+	}
+	want := "run1 sync vid001 Title 3, run1 sync vid002 Description 100000, run3 override vid001 Override.Exclude 4"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("entries = %s\nwant %s", strings.Join(got, ", "), want)
+	}
+}
@@ -1,10 +1,9 @@
-package main
+package mt3
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net"
 	"net/http"
 	"net/url"
@@ -14,7 +13,6 @@ import (
 	"path/filepath"
 	"runtime"
 
-	"golang.org/x/net/context"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 )
@@ -23,24 +21,25 @@ import (
 // initiate the authorization flow or just display the URL in the terminal
 // window. Note the following instructions based on this setting:
 // * launchWebServer = true
-//   1. Use OAuth2 credentials for a web application
-//   2. Define authorized redirect URIs for the credential in the Google APIs
-//      Console and set the RedirectURL property on the config object to one
-//      of those redirect URIs. For example:
-//      config.RedirectURL = "http://localhost:8090"
-//   3. In the startWebServer function below, update the URL in this line
-//      to match the redirect URI you selected:
-//         listener, err := net.Listen("tcp", "localhost:8090")
-//      The redirect URI identifies the URI to which the user is sent after
-//      completing the authorization flow. The listener then captures the
-//      authorization code in the URL and passes it back to this script.
+//  1. Use OAuth2 credentials for a web application
+//  2. Define authorized redirect URIs for the credential in the Google APIs
+//     Console and set the RedirectURL property on the config object to one
+//     of those redirect URIs. For example:
+//     config.RedirectURL = "http://localhost:8090"
+//  3. In the startWebServer function below, update the URL in this line
+//     to match the redirect URI you selected:
+//     listener, err := net.Listen("tcp", "localhost:8090")
+//     The redirect URI identifies the URI to which the user is sent after
+//     completing the authorization flow. The listener then captures the
+//     authorization code in the URL and passes it back to this script.
+//
 // * launchWebServer = false
-//   1. Use OAuth2 credentials for an installed application. (When choosing
-//      the application type for the OAuth2 client ID, select "Other".)
-//   2. Set the redirect URI to "urn:ietf:wg:oauth:2.0:oob", like this:
-//      config.RedirectURL = "urn:ietf:wg:oauth:2.0:oob"
-//   3. When running the script, complete the auth flow. Then copy the
-//      authorization code from the browser and enter it on the command line.
+//  1. Use OAuth2 credentials for an installed application. (When choosing
+//     the application type for the OAuth2 client ID, select "Other".)
+//  2. Set the redirect URI to "urn:ietf:wg:oauth:2.0:oob", like this:
+//     config.RedirectURL = "urn:ietf:wg:oauth:2.0:oob"
+//  3. When running the script, complete the auth flow. Then copy the
+//     authorization code from the browser and enter it on the command line.
 const launchWebServer = false
 
 const missingClientSecretsMessage = `
@@ -54,48 +53,51 @@ For more information about the client_secrets.json file format, please visit:
 https://developers.google.com/api-client-library/python/guide/aaa_client_secrets
 `
 
-// getClient uses a Context and Config to retrieve a Token
+// GetClient uses a Context and Config to retrieve a Token
 // then generate a Client. It returns the generated Client.
-func getClient(scope string) *http.Client {
-	ctx := context.Background()
-	
-	b, err := ioutil.ReadFile("client_secret.json")
+// ctx covers exchanging the authorization code and refreshing the token.
+func GetClient(ctx context.Context, scope string) (*http.Client, error) {
+
+	b, err := os.ReadFile("client_secret.json")
 	if err != nil {
-		log.Fatalf("Unable to read client secret file: %v", err)
+		return nil, fmt.Errorf("reading client secret file: %w", err)
 	}
-	
+
 	// If modifying the scope, delete your previously saved credentials
 	// at ~/.credentials/youtube-go.json
 	config, err := google.ConfigFromJSON(b, scope)
 	if err != nil {
-		log.Fatalf("Unable to parse client secret file to config: %v", err)
+		return nil, fmt.Errorf("parsing client secret file to config: %w", err)
 	}
-	
+
 	// Use a redirect URI like this for a web app. The redirect URI must be a
 	// valid one for your OAuth2 credentials.
 	config.RedirectURL = "http://localhost:8090"
 	// Use the following redirect URI if launchWebServer=false in oauth2.go
 	// config.RedirectURL = "urn:ietf:wg:oauth:2.0:oob"
-	
+
 	cacheFile, err := tokenCacheFile()
 	if err != nil {
-		log.Fatalf("Unable to get path to cached credential file. %v", err)
+		return nil, fmt.Errorf("getting path to cached credential file: %w", err)
 	}
 	tok, err := tokenFromFile(cacheFile)
 	if err != nil {
 		authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
 		if launchWebServer {
 			fmt.Println("Trying to get token from web")
-			tok, err = getTokenFromWeb(config, authURL)
+			tok, err = getTokenFromWeb(ctx, config, authURL)
 		} else {
 			fmt.Println("Trying to get token from prompt")
-			tok, err = getTokenFromPrompt(config, authURL)
+			tok, err = getTokenFromPrompt(ctx, config, authURL)
 		}
-		if err == nil {
-			saveToken(cacheFile, tok)
+		if err != nil {
+			return nil, err
+		}
+		if err := saveToken(cacheFile, tok); err != nil {
+			return nil, err
 		}
 	}
-	return config.Client(ctx, tok)
+	return config.Client(ctx, tok), nil
 }
 
 // startWebServer starts a web server that listens on http://localhost:8080.
@@ -120,7 +122,8 @@ func startWebServer() (codeCh chan string, err error) {
 
 // openURL opens a browser window to the specified location.
 // This code originally appeared at:
-//   http://stackoverflow.com/questions/10377243/how-can-i-launch-a-process-that-is-not-a-file-in-go
+//
+//	http://stackoverflow.com/questions/10377243/how-can-i-launch-a-process-that-is-not-a-file-in-go
 func openURL(url string) error {
 	var err error
 	switch runtime.GOOS {
@@ -137,32 +140,32 @@ func openURL(url string) error {
 }
 
 // Exchange the authorization code for an access token
-func exchangeToken(config *oauth2.Config, code string) (*oauth2.Token, error) {
-	tok, err := config.Exchange(oauth2.NoContext, code)
+func exchangeToken(ctx context.Context, config *oauth2.Config, code string) (*oauth2.Token, error) {
+	tok, err := config.Exchange(ctx, code)
 	if err != nil {
-		log.Fatalf("Unable to retrieve token %v", err)
+		return nil, fmt.Errorf("retrieving token: %w", err)
 	}
 	return tok, nil
 }
 
 // getTokenFromPrompt uses Config to request a Token and prompts the user
 // to enter the token on the command line. It returns the retrieved Token.
-func getTokenFromPrompt(config *oauth2.Config, authURL string) (*oauth2.Token, error) {
+func getTokenFromPrompt(ctx context.Context, config *oauth2.Config, authURL string) (*oauth2.Token, error) {
 	var code string
-	fmt.Printf("Go to the following link in your browser. After completing " +
-		"the authorization flow, enter the authorization code on the command " +
+	fmt.Printf("Go to the following link in your browser. After completing "+
+		"the authorization flow, enter the authorization code on the command "+
 		"line: \n%v\n", authURL)
 
 	if _, err := fmt.Scan(&code); err != nil {
-		log.Fatalf("Unable to read authorization code %v", err)
+		return nil, fmt.Errorf("reading authorization code: %w", err)
 	}
 	fmt.Println(authURL)
-	return exchangeToken(config, code)
+	return exchangeToken(ctx, config, code)
 }
 
 // getTokenFromWeb uses Config to request a Token.
 // It returns the retrieved Token.
-func getTokenFromWeb(config *oauth2.Config, authURL string) (*oauth2.Token, error) {
+func getTokenFromWeb(ctx context.Context, config *oauth2.Config, authURL string) (*oauth2.Token, error) {
 	codeCh, err := startWebServer()
 	if err != nil {
 		fmt.Printf("Unable to start a web server.")
@@ -171,16 +174,20 @@ func getTokenFromWeb(config *oauth2.Config, authURL string) (*oauth2.Token, erro
 
 	err = openURL(authURL)
 	if err != nil {
-		log.Fatalf("Unable to open authorization URL in web server: %v", err)
-	} else {
-		fmt.Println("Your browser has been opened to an authorization URL.",
-			" This program will resume once authorization has been provided.\n")
-		fmt.Println(authURL)
+		return nil, fmt.Errorf("opening authorization URL in web server: %w", err)
 	}
+	fmt.Println("Your browser has been opened to an authorization URL.",
+		" This program will resume once authorization has been provided.")
+	fmt.Println(authURL)
 
-	// Wait for the web server to get the code.
-	code := <-codeCh
-	return exchangeToken(config, code)
+	// Wait for the web server to get the code, or for the user to give up
+	var code string
+	select {
+	case code = <-codeCh:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return exchangeToken(ctx, config, code)
 }
 
 // tokenCacheFile generates credential file path/filename.
@@ -211,13 +218,13 @@ func tokenFromFile(file string) (*oauth2.Token, error) {
 
 // saveToken uses a file path to create a file and store the
 // token in it.
-func saveToken(file string, token *oauth2.Token) {
+func saveToken(file string, token *oauth2.Token) error {
 	fmt.Println("trying to save token")
 	fmt.Printf("Saving credential file to: %s\n", file)
 	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
-		log.Fatalf("Unable to cache oauth token: %v", err)
+		return fmt.Errorf("caching oauth token in %s: %w", file, err)
 	}
 	defer f.Close()
-	json.NewEncoder(f).Encode(token)
+	return json.NewEncoder(f).Encode(token)
 }
@@ -0,0 +1,160 @@
+package mt3
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// One rule from rules.toml, e.g.
+//
+//	[[Rule]]
+//	Name = "long streams"
+//	Type = "Livestream"
+//	WasLive = true
+//	MinDuration = "30m"
+//
+// Every condition that is set must match; a rule with no conditions matches everything.
+type ClassificationRule struct {
+	Name               string
+	Type               MT3VideoType
+	TitleMatches       string        // regular expression, e.g. "(?i)live ?stream"
+	DescriptionMatches string        // regular expression
+	AnyTag             []string      // at least one of these tags, ignoring case
+	MinDuration        time.Duration // rules with a duration limit never match videos without a Duration
+	MaxDuration        time.Duration
+	WasLive            *bool // YouTube has liveStreamingDetails for it
+	LiveChecked        *bool // we have asked YouTube for liveStreamingDetails (false means only the title is known)
+
+	titleRegexp       *regexp.Regexp
+	descriptionRegexp *regexp.Regexp
+}
+
+// Classifier decides the MT3VideoType of a video: the first rule that matches wins,
+// and Default is used when none do
+type Classifier struct {
+	Default MT3VideoType
+	Rule    []ClassificationRule
+}
+
+// DefaultClassifier is what we use without a rules file.  Anything YouTube says was broadcast live is a Livestream.
+// Until we have asked (see recordLiveStreamingDetails), "live stream" in the title is the best guess.
+func DefaultClassifier() *Classifier {
+	yes, no := true, false
+	classifier := &Classifier{
+		Default: Snippet,
+		Rule: []ClassificationRule{
+			{Name: "broadcast live", Type: Livestream, WasLive: &yes},
+			{Name: "live stream in title", Type: Livestream, LiveChecked: &no, TitleMatches: `(?i)live ?stream`},
+		},
+	}
+	if err := classifier.compile(); err != nil {
+		panic(err)
+	}
+	return classifier
+}
+
+// LoadClassifier reads a rules file (see ResolveRulesPath).  An empty path means DefaultClassifier.
+func LoadClassifier(rulesPath string) (*Classifier, error) {
+	if rulesPath == "" {
+		return DefaultClassifier(), nil
+	}
+	var classifier Classifier
+	metaData, err := toml.DecodeFile(rulesPath, &classifier)
+	if err != nil {
+		return nil, fmt.Errorf("reading rules file %s: %w", rulesPath, err)
+	}
+	// a misspelled condition would otherwise be silently ignored and match everything
+	if undecoded := metaData.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("rules file %s: unknown key %s", rulesPath, undecoded[0])
+	}
+	if classifier.Default == "" {
+		classifier.Default = Snippet
+	}
+	if err := classifier.compile(); err != nil {
+		return nil, fmt.Errorf("rules file %s: %w", rulesPath, err)
+	}
+	return &classifier, nil
+}
+
+// compile checks every rule and compiles its regular expressions
+func (classifier *Classifier) compile() error {
+	for i := range classifier.Rule {
+		rule := &classifier.Rule[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if rule.Type == "" {
+			return fmt.Errorf("%s has no Type", rule.Name)
+		}
+		var err error
+		if rule.TitleMatches != "" {
+			if rule.titleRegexp, err = regexp.Compile(rule.TitleMatches); err != nil {
+				return fmt.Errorf("%s: TitleMatches: %w", rule.Name, err)
+			}
+		}
+		if rule.DescriptionMatches != "" {
+			if rule.descriptionRegexp, err = regexp.Compile(rule.DescriptionMatches); err != nil {
+				return fmt.Errorf("%s: DescriptionMatches: %w", rule.Name, err)
+			}
+		}
+		if rule.MaxDuration != 0 && rule.MaxDuration < rule.MinDuration {
+			return fmt.Errorf("%s: MaxDuration %v is less than MinDuration %v", rule.Name, rule.MaxDuration, rule.MinDuration)
+		}
+	}
+	return nil
+}
+
+// matches says whether every condition of rule holds for video
+func (rule *ClassificationRule) matches(video VideoMeta) bool {
+	if rule.titleRegexp != nil && !rule.titleRegexp.MatchString(video.Title) {
+		return false
+	}
+	if rule.descriptionRegexp != nil && !rule.descriptionRegexp.MatchString(video.Description) {
+		return false
+	}
+	if len(rule.AnyTag) > 0 && !hasAnyTag(video.Tags, rule.AnyTag) {
+		return false
+	}
+	if rule.MinDuration != 0 || rule.MaxDuration != 0 {
+		if video.Duration == 0 || video.Duration < rule.MinDuration {
+			return false
+		}
+		if rule.MaxDuration != 0 && video.Duration > rule.MaxDuration {
+			return false
+		}
+	}
+	if rule.WasLive != nil && *rule.WasLive != video.WasLive {
+		return false
+	}
+	if rule.LiveChecked != nil && *rule.LiveChecked != video.LiveChecked {
+		return false
+	}
+	return true
+}
+
+func hasAnyTag(tags []string, wanted []string) bool {
+	for _, tag := range tags {
+		for _, want := range wanted {
+			if strings.EqualFold(tag, want) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Classify returns the type of video and the name of the rule that decided it,
+// or "" for the rule if no rule matched and Default was used
+func (classifier *Classifier) Classify(video VideoMeta) (MT3VideoType, string) {
+	for i := range classifier.Rule {
+		rule := &classifier.Rule[i]
+		if rule.matches(video) {
+			return rule.Type, rule.Name
+		}
+	}
+	return classifier.Default, ""
+}
@@ -0,0 +1,114 @@
+package mt3
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeRules writes a rules file into a temporary directory and returns its path
+func writeRules(t *testing.T, rules string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.toml")
+	if err := os.WriteFile(path, []byte(rules), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExampleRules(t *testing.T) {
+	classifier, err := LoadClassifier("../rules.example.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule := classifier.Rule[3]; rule.MaxDuration != time.Minute {
+		t.Errorf("%s MaxDuration = %v, want 1m from \"1m\"", rule.Name, rule.MaxDuration)
+	}
+	tests := []struct {
+		video    VideoMeta
+		wantType MT3VideoType
+		wantRule string
+	}{
+		// first match wins: a live timelapse is a livestream
+		{VideoMeta{WasLive: true, LiveChecked: true, Tags: []string{"timelapse"}, Duration: time.Hour}, Livestream, "broadcast live"},
+		{VideoMeta{Title: "MT3 Livestream 12", LiveChecked: false}, Livestream, "live stream in title"},
+		{VideoMeta{Title: "MT3 Livestream 12", LiveChecked: true, Duration: time.Hour}, Snippet, ""},
+		{VideoMeta{Tags: []string{"Time Lapse"}, LiveChecked: true, Duration: 5 * time.Minute}, "Timelapse", "timelapse"},
+		{VideoMeta{LiveChecked: true, Duration: 45 * time.Second}, "Short", "shorts"},
+		{VideoMeta{LiveChecked: true, Duration: time.Minute}, "Short", "shorts"},
+		{VideoMeta{LiveChecked: true, Duration: 61 * time.Second}, Snippet, ""},
+		// no duration yet is not a short
+		{VideoMeta{LiveChecked: true}, Snippet, ""},
+	}
+	for _, test := range tests {
+		gotType, gotRule := classifier.Classify(test.video)
+		if gotType != test.wantType || gotRule != test.wantRule {
+			t.Errorf("Classify(%+v) = %s by %q, want %s by %q", test.video, gotType, gotRule, test.wantType, test.wantRule)
+		}
+	}
+}
+
+func TestDefaultClassifier(t *testing.T) {
+	tests := []struct {
+		video VideoMeta
+		want  MT3VideoType
+	}{
+		{VideoMeta{Title: "Marble Track 3 live stream"}, Livestream},
+		{VideoMeta{Title: "Marble Track 3 LIVESTREAM"}, Livestream},
+		// once YouTube has been asked, only what it said counts
+		{VideoMeta{Title: "Marble Track 3 live stream", LiveChecked: true}, Snippet},
+		{VideoMeta{Title: "Marble Track 3 snippet", LiveChecked: true, WasLive: true}, Livestream},
+		{VideoMeta{Title: "Marble Track 3 snippet"}, Snippet},
+	}
+	classifier := DefaultClassifier()
+	for _, test := range tests {
+		if got, _ := classifier.Classify(test.video); got != test.want {
+			t.Errorf("Classify(%+v) = %s, want %s", test.video, got, test.want)
+		}
+	}
+}
+
+func TestLoadClassifierRules(t *testing.T) {
+	classifier, err := LoadClassifier(writeRules(t, `
+[[Rule]]
+Type = "Medium"
+MinDuration = "1m30s"
+MaxDuration = "2h"
+DescriptionMatches = 'marble'
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := classifier.Rule[0]
+	if rule.Name != "rule 1" || rule.MinDuration != 90*time.Second || rule.MaxDuration != 2*time.Hour {
+		t.Errorf("rule = %+v", rule)
+	}
+	if classifier.Default != Snippet {
+		t.Errorf("Default = %q, want Snippet when the file does not say", classifier.Default)
+	}
+	if got, _ := classifier.Classify(VideoMeta{Description: "a marble run", Duration: 2 * time.Minute}); got != "Medium" {
+		t.Errorf("got %s, want Medium", got)
+	}
+}
+
+func TestLoadClassifierErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		want  string
+	}{
+		{"misspelled condition", "[[Rule]]\nType = \"Short\"\nMaxDuraton = \"1m\"\n", "unknown key Rule.MaxDuraton"},
+		{"no type", "[[Rule]]\nName = \"typeless\"\nWasLive = true\n", "typeless has no Type"},
+		{"limits backwards", "[[Rule]]\nType = \"Odd\"\nMinDuration = \"10m\"\nMaxDuration = \"1m\"\n", "MaxDuration 1m0s is less than MinDuration 10m0s"},
+		{"bad regexp", "[[Rule]]\nType = \"Odd\"\nTitleMatches = '('\n", "TitleMatches"},
+		{"bad duration", "[[Rule]]\nType = \"Odd\"\nMinDuration = \"a while\"\n", "reading rules file"},
+	}
+	for _, test := range tests {
+		_, err := LoadClassifier(writeRules(t, test.rules))
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got %v, want an error mentioning %q", test.name, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package mt3
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// AppName is the directory name used under the XDG config and data dirs
+const AppName = "go-get-video-durations"
+
+// StoreEnvVar can point at the knownvideos file without touching the config file
+const StoreEnvVar = "MT3_KNOWNVIDEOS"
+
+// This is the structure of config.toml, e.g.
+//
+//	store = "~/mt3.com/data/playlists/knownvideos.toml"
+//	hugo_content = "~/mt3.com/content"
+//	rules = "~/mt3.com/data/playlists/rules.toml"
+//	quota_budget = 2000
+type appConfig struct {
+	Store       string
+	HugoContent string `toml:"hugo_content"`
+	Rules       string
+	QuotaBudget int `toml:"quota_budget"`
+}
+
+// xdgDir returns $envVar if it is set to an absolute path, else ~/fallback
+// See https://specifications.freedesktop.org/basedir-spec/latest/
+func xdgDir(envVar string, fallback string) (string, error) {
+	if dir := os.Getenv(envVar); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, fallback), nil
+}
+
+// configFilePath is where config.toml lives, normally ~/.config/go-get-video-durations/config.toml
+func configFilePath() (string, error) {
+	dir, err := xdgDir("XDG_CONFIG_HOME", ".config")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, AppName, "config.toml"), nil
+}
+
+// defaultStorePath is used when nobody told us where knownvideos.toml is,
+// normally ~/.local/share/go-get-video-durations/knownvideos.toml
+func defaultStorePath() (string, error) {
+	dir, err := xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, AppName, "knownvideos.toml"), nil
+}
+
+// QuotaLedgerPath is where QuotaLedger keeps its counts,
+// normally ~/.local/share/go-get-video-durations/quota.json
+func QuotaLedgerPath() (string, error) {
+	dir, err := xdgDir("XDG_DATA_HOME", filepath.Join(".local", "share"))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, AppName, "quota.json"), nil
+}
+
+// loadConfig reads config.toml.  A missing config file is not an error.
+func loadConfig() (appConfig, error) {
+	var config appConfig
+	path, err := configFilePath()
+	if err != nil {
+		return config, err
+	}
+	_, err = toml.DecodeFile(path, &config)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("reading config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// expandHome turns ~/something into /home/me/something
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+// ResolveStorePath decides where the knownvideos file is.  First one wins:
+//
+//	--store flag
+//	$MT3_KNOWNVIDEOS
+//	store = "..." in config.toml
+//	XDG data dir default
+func ResolveStorePath(flagValue string) (string, error) {
+	path := flagValue
+	if path == "" {
+		path = os.Getenv(StoreEnvVar)
+	}
+	if path == "" {
+		config, err := loadConfig()
+		if err != nil {
+			return "", err
+		}
+		path = config.Store
+	}
+	if path == "" {
+		return defaultStorePath()
+	}
+	return expandHome(path)
+}
+
+// ResolveRulesPath decides which rules file classifies videos.  First one wins:
+//
+//	--rules flag
+//	rules = "..." in config.toml
+//	rules.toml next to config.toml, if it exists
+//
+// "" means there is none and the built in DefaultClassifier is used.
+func ResolveRulesPath(flagValue string) (string, error) {
+	path := flagValue
+	if path == "" {
+		config, err := loadConfig()
+		if err != nil {
+			return "", err
+		}
+		path = config.Rules
+	}
+	if path != "" {
+		return expandHome(path)
+	}
+	configPath, err := configFilePath()
+	if err != nil {
+		return "", err
+	}
+	path = filepath.Join(filepath.Dir(configPath), "rules.toml")
+	if _, err := os.Stat(path); err != nil {
+		return "", nil
+	}
+	return path, nil
+}
+
+// ResolveHugoContentDir decides where the export command writes Hugo content.  First one wins:
+//
+//	--content-dir flag
+//	hugo_content = "..." in config.toml
+//
+// There is no default, so we never scribble over a directory nobody asked for.
+func ResolveHugoContentDir(flagValue string) (string, error) {
+	path := flagValue
+	if path == "" {
+		config, err := loadConfig()
+		if err != nil {
+			return "", err
+		}
+		path = config.HugoContent
+	}
+	if path == "" {
+		return "", fmt.Errorf("no Hugo content directory; pass --content-dir or set hugo_content in %s", configFileDescription())
+	}
+	return expandHome(path)
+}
+
+// configFileDescription is the config.toml path for error messages, or a generic name if we cannot find it
+func configFileDescription() string {
+	path, err := configFilePath()
+	if err != nil {
+		return "config.toml"
+	}
+	return path
+}
+
+// ResolveQuotaBudget decides how many quota units a day we allow ourselves.  First one wins:
+//
+//	--quota-budget flag, if not 0
+//	quota_budget = ... in config.toml
+//	DefaultQuotaBudget
+func ResolveQuotaBudget(flagValue int) (int, error) {
+	if flagValue != 0 {
+		return flagValue, nil
+	}
+	config, err := loadConfig()
+	if err != nil {
+		return 0, err
+	}
+	if config.QuotaBudget != 0 {
+		return config.QuotaBudget, nil
+	}
+	return DefaultQuotaBudget, nil
+}
@@ -0,0 +1,220 @@
+package mt3
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+// FakeYouTube is an in-memory YouTubeAPI for exercising the sync logic offline.
+// Fill it with AddUpload and friends, hand it to LoadNewVideosFromMyChannel or FillInDurations,
+// then look at Calls to see what was asked for.
+type FakeYouTube struct {
+	ChannelId         string
+	UploadsPlaylistId string
+	PlaylistItems     []*youtube.PlaylistItem // uploads, in the order the API returns them
+	Videos            map[string]*youtube.Video
+	Playlists         []*youtube.Playlist
+	SearchResults     []*youtube.SearchResult
+
+	// Errors makes a method fail, keyed by method name, e.g. Errors["VideosListMultipleIds"]
+	Errors map[string]error
+	// ErrorsLeft, if set for a method, is how many more of its calls Errors fails; after that it works again
+	ErrorsLeft map[string]int
+	// Calls records every call as "Method arg1 arg2..."
+	Calls []string
+}
+
+func NewFakeYouTube() *FakeYouTube {
+	return &FakeYouTube{
+		ChannelId:         "UCfakechannel",
+		UploadsPlaylistId: "UUfakechannel",
+		Videos:            make(map[string]*youtube.Video),
+		Errors:            make(map[string]error),
+		ErrorsLeft:        make(map[string]int),
+	}
+}
+
+// AddUpload adds a video to the uploads playlist and to videos.list.
+// isoDuration is what contentDetails.duration should say, e.g. PT1H2M3S
+func (f *FakeYouTube) AddUpload(videoId string, title string, published time.Time, isoDuration string) {
+	f.PlaylistItems = append(f.PlaylistItems, &youtube.PlaylistItem{
+		Id: "item-" + videoId,
+		Snippet: &youtube.PlaylistItemSnippet{
+			Title:       title,
+			PlaylistId:  f.UploadsPlaylistId,
+			PublishedAt: published.Format(time.RFC3339),
+			ResourceId:  &youtube.ResourceId{Kind: "youtube#video", VideoId: videoId},
+		},
+		ContentDetails: &youtube.PlaylistItemContentDetails{
+			VideoId:          videoId,
+			VideoPublishedAt: published.Format(time.RFC3339),
+		},
+	})
+	f.Videos[videoId] = &youtube.Video{
+		Id:             videoId,
+		Snippet:        &youtube.VideoSnippet{Title: title, PublishedAt: published.Format(time.RFC3339), ChannelId: f.ChannelId},
+		ContentDetails: &youtube.VideoContentDetails{Duration: isoDuration},
+	}
+}
+
+// record notes the call and returns the error configured for it, if any.
+// A cancelled ctx fails the call the way it would fail a real one.
+func (f *FakeYouTube) record(ctx context.Context, method string, args ...string) error {
+	f.Calls = append(f.Calls, strings.TrimSpace(method+" "+strings.Join(args, " ")))
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := f.Errors[method]
+	if left, limited := f.ErrorsLeft[method]; err != nil && limited {
+		if left <= 0 {
+			return nil
+		}
+		f.ErrorsLeft[method] = left - 1
+	}
+	return err
+}
+
+func (f *FakeYouTube) ChannelsListMine(ctx context.Context, part string) (*youtube.ChannelListResponse, error) {
+	if err := f.record(ctx, "ChannelsListMine", part); err != nil {
+		return nil, err
+	}
+	return &youtube.ChannelListResponse{
+		Items: []*youtube.Channel{{
+			Id: f.ChannelId,
+			ContentDetails: &youtube.ChannelContentDetails{
+				RelatedPlaylists: &youtube.ChannelContentDetailsRelatedPlaylists{Uploads: f.UploadsPlaylistId},
+			},
+		}},
+	}, nil
+}
+
+// PlaylistItemsList pages through PlaylistItems.  Page tokens are just the offset of the next page.
+func (f *FakeYouTube) PlaylistItemsList(ctx context.Context, part string, playlistId string, pageToken string, numItems int64) (*youtube.PlaylistItemListResponse, error) {
+	if err := f.record(ctx, "PlaylistItemsList", part, playlistId, pageToken, strconv.FormatInt(numItems, 10)); err != nil {
+		return nil, err
+	}
+	if playlistId != f.UploadsPlaylistId {
+		return nil, fmt.Errorf("fake playlist %q not found", playlistId)
+	}
+	start := 0
+	if pageToken != "" {
+		var err error
+		if start, err = strconv.Atoi(pageToken); err != nil || start < 0 || start > len(f.PlaylistItems) {
+			// what YouTube says about a token it does not know, or one that has expired
+			return nil, &googleapi.Error{
+				Code:    http.StatusBadRequest,
+				Message: fmt.Sprintf("fake page token %q is invalid", pageToken),
+				Errors:  []googleapi.ErrorItem{{Reason: "invalidPageToken"}},
+			}
+		}
+	}
+	if numItems <= 0 {
+		numItems = 5 // the API default
+	}
+	end := start + int(numItems)
+	response := &youtube.PlaylistItemListResponse{}
+	if end < len(f.PlaylistItems) {
+		response.NextPageToken = strconv.Itoa(end)
+	} else {
+		end = len(f.PlaylistItems)
+	}
+	response.Items = f.PlaylistItems[start:end]
+	response.PageInfo = &youtube.PageInfo{TotalResults: int64(len(f.PlaylistItems)), ResultsPerPage: numItems}
+	return response, nil
+}
+
+// VideosListMultipleIds returns the known videos among the comma separated ids.
+// Unknown ids are left out, which is what YouTube does for deleted videos.
+func (f *FakeYouTube) VideosListMultipleIds(ctx context.Context, part string, id string) (*youtube.VideoListResponse, error) {
+	if err := f.record(ctx, "VideosListMultipleIds", part, id); err != nil {
+		return nil, err
+	}
+	ids := strings.Split(id, ",")
+	if len(ids) > MaxIdsPerVideosList {
+		return nil, fmt.Errorf("fake videos.list got %d ids, the limit is %d", len(ids), MaxIdsPerVideosList)
+	}
+	response := &youtube.VideoListResponse{}
+	for _, videoId := range ids {
+		if video, ok := f.Videos[videoId]; ok {
+			response.Items = append(response.Items, video)
+		}
+	}
+	return response, nil
+}
+
+// PlaylistsList filters Playlists by id or channel; mine means our own channel
+func (f *FakeYouTube) PlaylistsList(ctx context.Context, part string, query PlaylistsQuery) (*youtube.PlaylistListResponse, error) {
+	if err := f.record(ctx, "PlaylistsList", part, query.ChannelId, query.PlaylistId); err != nil {
+		return nil, err
+	}
+	response := &youtube.PlaylistListResponse{}
+	for _, playlist := range f.Playlists {
+		channelId := ""
+		if playlist.Snippet != nil {
+			channelId = playlist.Snippet.ChannelId
+		}
+		switch {
+		case query.PlaylistId != "" && playlist.Id != query.PlaylistId:
+			continue
+		case query.ChannelId != "" && channelId != query.ChannelId:
+			continue
+		case query.Mine && channelId != f.ChannelId:
+			continue
+		}
+		response.Items = append(response.Items, playlist)
+	}
+	return response, nil
+}
+
+// SearchList returns the SearchResults whose title contains query, ignoring case
+func (f *FakeYouTube) SearchList(ctx context.Context, part string, query string, maxResults int64) (*youtube.SearchListResponse, error) {
+	if err := f.record(ctx, "SearchList", part, query, strconv.FormatInt(maxResults, 10)); err != nil {
+		return nil, err
+	}
+	response := &youtube.SearchListResponse{}
+	for _, result := range f.SearchResults {
+		if int64(len(response.Items)) == maxResults {
+			break
+		}
+		if result.Snippet != nil && strings.Contains(strings.ToLower(result.Snippet.Title), strings.ToLower(query)) {
+			response.Items = append(response.Items, result)
+		}
+	}
+	return response, nil
+}
+
+// VideosInsert reads all of media and adds the video to the front of the uploads playlist
+func (f *FakeYouTube) VideosInsert(ctx context.Context, part string, video *youtube.Video, media io.Reader) (*youtube.Video, error) {
+	// an upload reads the media before it can fail, like a real one that dies part way
+	err := f.record(ctx, "VideosInsert", part)
+	size, readErr := io.Copy(io.Discard, media)
+	if err != nil {
+		return nil, err
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	uploaded := *video
+	uploaded.FileDetails = &youtube.VideoFileDetails{FileSize: uint64(size)}
+	uploaded.Id = fmt.Sprintf("fakeUpload%03d", len(f.Videos)+1)
+	f.Videos[uploaded.Id] = &uploaded
+	title := ""
+	if uploaded.Snippet != nil {
+		title = uploaded.Snippet.Title
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	f.PlaylistItems = append([]*youtube.PlaylistItem{{
+		Id:             "item-" + uploaded.Id,
+		Snippet:        &youtube.PlaylistItemSnippet{Title: title, PlaylistId: f.UploadsPlaylistId, PublishedAt: now, ResourceId: &youtube.ResourceId{Kind: "youtube#video", VideoId: uploaded.Id}},
+		ContentDetails: &youtube.PlaylistItemContentDetails{VideoId: uploaded.Id, VideoPublishedAt: now},
+	}}, f.PlaylistItems...)
+	return &uploaded, nil
+}
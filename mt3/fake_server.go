@@ -0,0 +1,379 @@
+package mt3
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// FakeYouTubeServer speaks just enough of the YouTube Data API v3 REST surface
+// for every command, so a whole sync can run in CI with no network.
+// The commands start it with --fake-api=testdata/fakeyoutube (see NewFakeYouTubeService in api.go).
+//
+// The fixture directory holds these files, each optional:
+//
+//	channels.json       ChannelListResponse returned for channels.list?mine=true
+//	playlistItems.json  {"<playlistId>": [PlaylistItem, ...]}, paged with maxResults and pageToken
+//	videos.json         [Video, ...] looked up by videos.list?id=a,b,c
+//	playlists.json      [Playlist, ...] filtered by id, channelId or mine
+//	search.json         [SearchResult, ...] matched against search.list?q= in the title or description
+//
+// videos.insert accepts multipart and resumable uploads and adds the new video to videos.json in memory.
+type FakeYouTubeServer struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	channels      youtube.ChannelListResponse
+	playlistItems map[string][]*youtube.PlaylistItem
+	videos        []*youtube.Video
+	playlists     []*youtube.Playlist
+	searchResults []*youtube.SearchResult
+
+	uploads       map[string]*fakeUpload // resumable uploads in progress, by upload_id
+	nextSessionId int
+	nextUploadId  int
+}
+
+// fakeUpload is a resumable upload that has not received all of its bytes yet
+type fakeUpload struct {
+	video    *youtube.Video
+	received int64
+}
+
+// StartFakeYouTubeServer loads the fixtures in fixtureDir and starts serving them
+func StartFakeYouTubeServer(fixtureDir string) (*FakeYouTubeServer, error) {
+	fake := &FakeYouTubeServer{
+		playlistItems: make(map[string][]*youtube.PlaylistItem),
+		uploads:       make(map[string]*fakeUpload),
+	}
+	fixtures := []struct {
+		name string
+		into interface{}
+	}{
+		{"channels.json", &fake.channels},
+		{"playlistItems.json", &fake.playlistItems},
+		{"videos.json", &fake.videos},
+		{"playlists.json", &fake.playlists},
+		{"search.json", &fake.searchResults},
+	}
+	for _, fixture := range fixtures {
+		b, err := os.ReadFile(filepath.Join(fixtureDir, fixture.name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, fixture.into); err != nil {
+			return nil, fmt.Errorf("fixture %s: %w", filepath.Join(fixtureDir, fixture.name), err)
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/youtube/v3/channels", fake.handleChannels)
+	mux.HandleFunc("/youtube/v3/playlistItems", fake.handlePlaylistItems)
+	mux.HandleFunc("/youtube/v3/videos", fake.handleVideos)
+	mux.HandleFunc("/youtube/v3/playlists", fake.handlePlaylists)
+	mux.HandleFunc("/youtube/v3/search", fake.handleSearch)
+	mux.HandleFunc("/upload/youtube/v3/videos", fake.handleUpload)
+	fake.Server = httptest.NewServer(mux)
+	return fake, nil
+}
+
+// Endpoint is what option.WithEndpoint needs to send the client here.
+// The client library adds youtube/v3/... (or upload/youtube/v3/... for uploads) itself.
+func (fake *FakeYouTubeServer) Endpoint() string {
+	return fake.URL + "/"
+}
+
+// writeJSON sends v, or a googleapi style error if status is not 200
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError looks enough like a real error response for googleapi.CheckResponse to parse
+func writeAPIError(w http.ResponseWriter, status int, reason string, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    status,
+			"message": message,
+			"errors":  []map[string]string{{"domain": "youtube.fake", "reason": reason, "message": message}},
+		},
+	})
+}
+
+// pageBounds turns maxResults and pageToken into a slice range.  Page tokens are offsets.
+func pageBounds(r *http.Request, total int) (start int, end int, next string, err error) {
+	maxResults := 5 // the API default
+	if s := r.FormValue("maxResults"); s != "" {
+		if maxResults, err = strconv.Atoi(s); err != nil || maxResults < 0 || maxResults > 50 {
+			return 0, 0, "", fmt.Errorf("invalid maxResults %q", s)
+		}
+	}
+	if s := r.FormValue("pageToken"); s != "" {
+		if start, err = strconv.Atoi(s); err != nil || start < 0 || start > total {
+			return 0, 0, "", fmt.Errorf("invalid pageToken %q", s)
+		}
+	}
+	end = start + maxResults
+	if end < total {
+		next = strconv.Itoa(end)
+	} else {
+		end = total
+	}
+	return start, end, next, nil
+}
+
+func (fake *FakeYouTubeServer) handleChannels(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("mine") != "true" {
+		writeAPIError(w, http.StatusBadRequest, "missingRequiredParameter", "the fake only supports channels.list with mine=true")
+		return
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	writeJSON(w, http.StatusOK, fake.channels)
+}
+
+func (fake *FakeYouTubeServer) handlePlaylistItems(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	items, ok := fake.playlistItems[r.FormValue("playlistId")]
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "playlistNotFound", "playlist "+r.FormValue("playlistId")+" not found")
+		return
+	}
+	start, end, next, err := pageBounds(r, len(items))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalidParameter", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, youtube.PlaylistItemListResponse{
+		Kind:          "youtube#playlistItemListResponse",
+		Items:         items[start:end],
+		NextPageToken: next,
+		PageInfo:      &youtube.PageInfo{TotalResults: int64(len(items)), ResultsPerPage: int64(end - start)},
+	})
+}
+
+// handleVideos is videos.list by id.  A POST here is videos.insert without media, which YouTube refuses too.
+func (fake *FakeYouTubeServer) handleVideos(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		writeAPIError(w, http.StatusBadRequest, "mediaBodyRequired", "videos.insert needs a video file")
+		return
+	}
+	ids := strings.Split(r.FormValue("id"), ",")
+	if len(ids) > MaxIdsPerVideosList {
+		writeAPIError(w, http.StatusBadRequest, "invalidParameter", fmt.Sprintf("%d ids, the limit is %d", len(ids), MaxIdsPerVideosList))
+		return
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	response := youtube.VideoListResponse{Kind: "youtube#videoListResponse"}
+	// Missing ids are left out, just like YouTube does for deleted videos
+	for _, id := range ids {
+		for _, video := range fake.videos {
+			if video.Id == id {
+				response.Items = append(response.Items, video)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (fake *FakeYouTubeServer) handlePlaylists(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	myChannelId := ""
+	if len(fake.channels.Items) > 0 {
+		myChannelId = fake.channels.Items[0].Id
+	}
+	var matches []*youtube.Playlist
+	for _, playlist := range fake.playlists {
+		channelId := ""
+		if playlist.Snippet != nil {
+			channelId = playlist.Snippet.ChannelId
+		}
+		switch {
+		case r.FormValue("id") != "" && playlist.Id != r.FormValue("id"):
+			continue
+		case r.FormValue("channelId") != "" && channelId != r.FormValue("channelId"):
+			continue
+		case r.FormValue("mine") == "true" && channelId != myChannelId:
+			continue
+		}
+		matches = append(matches, playlist)
+	}
+	start, end, next, err := pageBounds(r, len(matches))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalidParameter", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, youtube.PlaylistListResponse{
+		Kind:          "youtube#playlistListResponse",
+		Items:         matches[start:end],
+		NextPageToken: next,
+		PageInfo:      &youtube.PageInfo{TotalResults: int64(len(matches)), ResultsPerPage: int64(end - start)},
+	})
+}
+
+func (fake *FakeYouTubeServer) handleSearch(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	query := strings.ToLower(r.FormValue("q"))
+	var matches []*youtube.SearchResult
+	for _, result := range fake.searchResults {
+		text := ""
+		if result.Snippet != nil {
+			text = strings.ToLower(result.Snippet.Title + " " + result.Snippet.Description)
+		}
+		for _, word := range strings.Fields(query) {
+			if strings.Contains(text, word) {
+				matches = append(matches, result)
+				break
+			}
+		}
+	}
+	start, end, next, err := pageBounds(r, len(matches))
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "invalidParameter", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, youtube.SearchListResponse{
+		Kind:          "youtube#searchListResponse",
+		Items:         matches[start:end],
+		NextPageToken: next,
+		PageInfo:      &youtube.PageInfo{TotalResults: int64(len(matches)), ResultsPerPage: int64(end - start)},
+	})
+}
+
+// addUploadedVideo gives the video an id and makes it show up in videos.list and the uploads playlist.
+// Must be called with fake.mu held.
+func (fake *FakeYouTubeServer) addUploadedVideo(video *youtube.Video) {
+	fake.nextUploadId++
+	video.Id = fmt.Sprintf("fakeUpload%03d", fake.nextUploadId)
+	video.Kind = "youtube#video"
+	fake.videos = append(fake.videos, video)
+	for _, channel := range fake.channels.Items {
+		if channel.ContentDetails == nil || channel.ContentDetails.RelatedPlaylists == nil {
+			continue
+		}
+		uploads := channel.ContentDetails.RelatedPlaylists.Uploads
+		title := ""
+		if video.Snippet != nil {
+			title = video.Snippet.Title
+		}
+		now := time.Now().UTC().Format(time.RFC3339)
+		item := &youtube.PlaylistItem{
+			Id:             "item-" + video.Id,
+			Snippet:        &youtube.PlaylistItemSnippet{Title: title, PlaylistId: uploads, PublishedAt: now, ResourceId: &youtube.ResourceId{Kind: "youtube#video", VideoId: video.Id}},
+			ContentDetails: &youtube.PlaylistItemContentDetails{VideoId: video.Id, VideoPublishedAt: now},
+		}
+		// New uploads come first, like the real uploads playlist
+		fake.playlistItems[uploads] = append([]*youtube.PlaylistItem{item}, fake.playlistItems[uploads]...)
+	}
+}
+
+// contentRange matches "bytes 0-99/100", "bytes 0-99/*" and "bytes */100"
+var contentRange = regexp.MustCompile(`^bytes (\*|(\d+)-(\d+))/(\*|\d+)$`)
+
+// handleUpload is videos.insert.  uploadType=multipart carries the metadata and media in one request;
+// uploadType=resumable starts a session that the client then PUTs chunks to.
+func (fake *FakeYouTubeServer) handleUpload(w http.ResponseWriter, r *http.Request) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+
+	if uploadId := r.FormValue("upload_id"); uploadId != "" {
+		fake.continueResumableUpload(w, r, uploadId)
+		return
+	}
+
+	video := &youtube.Video{}
+	switch r.FormValue("uploadType") {
+	case "multipart":
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "badContent", err.Error())
+			return
+		}
+		parts := multipart.NewReader(r.Body, params["boundary"])
+		metadata, err := parts.NextPart()
+		if err == nil {
+			err = json.NewDecoder(metadata).Decode(video)
+		}
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "badContent", "reading video metadata: "+err.Error())
+			return
+		}
+		media, err := parts.NextPart()
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, "mediaBodyRequired", "no media in upload")
+			return
+		}
+		io.Copy(io.Discard, media)
+	case "resumable":
+		if err := json.NewDecoder(r.Body).Decode(video); err != nil && err != io.EOF {
+			writeAPIError(w, http.StatusBadRequest, "badContent", "reading video metadata: "+err.Error())
+			return
+		}
+		fake.nextSessionId++
+		uploadId := strconv.Itoa(fake.nextSessionId)
+		fake.uploads[uploadId] = &fakeUpload{video: video}
+		w.Header().Set("Location", fake.URL+"/upload/youtube/v3/videos?uploadType=resumable&upload_id="+uploadId)
+		w.WriteHeader(http.StatusOK)
+		return
+	default:
+		writeAPIError(w, http.StatusBadRequest, "mediaBodyRequired", "videos.insert needs uploadType=multipart or resumable")
+		return
+	}
+	fake.addUploadedVideo(video)
+	writeJSON(w, http.StatusOK, video)
+}
+
+// continueResumableUpload takes one chunk.  Until the last one we answer "308 Resume Incomplete",
+// sent as a 200 with an override header because the client asks for that with X-GUploader-No-308.
+// Must be called with fake.mu held.
+func (fake *FakeYouTubeServer) continueResumableUpload(w http.ResponseWriter, r *http.Request, uploadId string) {
+	upload, ok := fake.uploads[uploadId]
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "uploadNotFound", "no upload session "+uploadId)
+		return
+	}
+	n, _ := io.Copy(io.Discard, r.Body)
+	upload.received += n
+
+	match := contentRange.FindStringSubmatch(r.Header.Get("Content-Range"))
+	if match == nil {
+		writeAPIError(w, http.StatusBadRequest, "badContent", "bad Content-Range "+r.Header.Get("Content-Range"))
+		return
+	}
+	if total := match[4]; total != "*" && total == strconv.FormatInt(upload.received, 10) {
+		delete(fake.uploads, uploadId)
+		fake.addUploadedVideo(upload.video)
+		writeJSON(w, http.StatusOK, upload.video)
+		return
+	}
+	if upload.received > 0 {
+		w.Header().Set("Range", fmt.Sprintf("bytes=0-%d", upload.received-1))
+	}
+	if r.Header.Get("X-GUploader-No-308") == "yes" {
+		w.Header().Set("X-Http-Status-Code-Override", "308")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.WriteHeader(308)
+}
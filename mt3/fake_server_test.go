@@ -0,0 +1,88 @@
+package mt3
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// TestSyncAgainstFakeServer runs a whole sync through YouTubeService, the real client library
+// and FakeYouTubeServer, the way sync --fake-api=testdata/fakeyoutube does
+func TestSyncAgainstFakeServer(t *testing.T) {
+	service, err := NewFakeYouTubeService("../testdata/fakeyoutube")
+	if err != nil {
+		t.Fatal(err)
+	}
+	api := NewRetryingYouTube(service, RetryPolicy{MaxAttempts: 1})
+	ctx := context.Background()
+
+	var knownVideos KnownVideos
+	summary, err := LoadNewVideosFromMyChannel(ctx, api, &knownVideos, SyncOptions{FullSync: true}, DefaultClassifier())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Added != 55 || len(knownVideos.Videos) != 55 {
+		t.Fatalf("summary = %v with %d videos, want 55 added", summary, len(knownVideos.Videos))
+	}
+	if err := FillInDurations(ctx, api, &knownVideos, DefaultClassifier(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		videoId      string
+		duration     time.Duration
+		videoType    MT3VideoType
+		availability VideoAvailability
+	}{
+		{"mt3video001", time.Hour, Livestream, VideoAvailable},
+		{"mt3video002", 2*time.Minute + 11*time.Second, Snippet, VideoAvailable},
+		{"mt3video020", 2*time.Minute + 29*time.Second, Snippet, VideoPrivate},
+		{"mt3video051", 26*time.Hour + 3*time.Minute, Livestream, VideoAvailable},
+		{"mt3video055", 0, Livestream, VideoAvailable}, // an upcoming stream: P0D, no length yet
+	}
+	for _, test := range tests {
+		video := knownVideos.Videos[test.videoId]
+		if video.Duration != test.duration || video.VideoType != test.videoType || video.Availability != test.availability {
+			t.Errorf("%s = %v %s %q, want %v %s %q", test.videoId, video.Duration, video.VideoType, video.Availability,
+				test.duration, test.videoType, test.availability)
+		}
+	}
+
+	// An incremental sync straight after finds nothing new
+	summary, err = LoadNewVideosFromMyChannel(ctx, api, &knownVideos, SyncOptions{}, DefaultClassifier())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Added != 0 || summary.Updated != 0 {
+		t.Errorf("incremental summary = %v, want nothing new", summary)
+	}
+}
+
+func TestUploadToFakeServer(t *testing.T) {
+	service, err := NewFakeYouTubeService("../testdata/fakeyoutube")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	upload := &youtube.Video{
+		Snippet: &youtube.VideoSnippet{Title: "Uploaded in a test"},
+		Status:  &youtube.VideoStatus{PrivacyStatus: "private"},
+	}
+	uploaded, err := service.VideosInsert(ctx, "snippet,status", upload, strings.NewReader("not really a video"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uploaded.Id == "" {
+		t.Fatal("upload came back without an ID")
+	}
+	response, err := service.VideosListMultipleIds(ctx, "snippet", uploaded.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Items) != 1 || response.Items[0].Snippet.Title != "Uploaded in a test" {
+		t.Errorf("videos.list after the upload = %+v", response.Items)
+	}
+}
@@ -0,0 +1,173 @@
+package mt3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// HugoSection is the content subdirectory a video of this type goes in, so the site
+// can give livestreams and snippets their own list pages and layouts.
+// Types from the rules file get the lower case plural of their name, e.g. Timelapse goes in timelapses.
+func HugoSection(videoType MT3VideoType) string {
+	switch videoType {
+	case Unknown, "":
+		return "videos"
+	}
+	return strings.ToLower(string(videoType)) + "s"
+}
+
+// hugoSections lists the subdirectories of contentDir, for finding files left behind when a video changes type
+func hugoSections(contentDir string) ([]string, error) {
+	entries, err := os.ReadDir(contentDir)
+	if err != nil {
+		return nil, err
+	}
+	var sections []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			sections = append(sections, entry.Name())
+		}
+	}
+	return sections, nil
+}
+
+// The TOML front matter of one exported video
+type hugoFrontMatter struct {
+	Id              string    `toml:"id"`
+	Title           string    `toml:"title"`
+	Date            time.Time `toml:"date"`
+	Duration        string    `toml:"duration"`
+	DurationSeconds int64     `toml:"duration_seconds"`
+	Type            string    `toml:"video_type"`
+	Tags            []string  `toml:"tags"`
+	Draft           bool      `toml:"draft,omitempty"` // private or removed on YouTube, so the embed would not play
+}
+
+// hugoContent is the whole .md file for video: front matter, then the YouTube shortcode
+func hugoContent(video VideoMeta) ([]byte, error) {
+	frontMatter := hugoFrontMatter{
+		Id:              video.VideoId,
+		Title:           video.Title,
+		Date:            video.Published.UTC(),
+		Duration:        video.Duration.String(),
+		DurationSeconds: int64(video.Duration / time.Second),
+		Type:            video.VideoType.String(),
+		Tags:            video.Tags,
+		Draft:           video.Availability != VideoAvailable,
+	}
+	if frontMatter.Tags == nil {
+		frontMatter.Tags = []string{}
+	}
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintln(buf, "+++")
+	if err := toml.NewEncoder(buf).Encode(frontMatter); err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(buf, "+++")
+	fmt.Fprintf(buf, "\n{{< youtube %s >}}\n", video.VideoId)
+	return buf.Bytes(), nil
+}
+
+// exportedHugoFile says whether path is a copy of videoId written by ExportHugo, going by its front matter.
+// Anything else, e.g. a post of the same name someone wrote by hand, is not.
+func exportedHugoFile(path string, videoId string) bool {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	parts := strings.SplitN(string(data), "+++\n", 3)
+	if len(parts) != 3 || parts[0] != "" {
+		return false
+	}
+	var frontMatter hugoFrontMatter
+	if _, err := toml.Decode(parts[1], &frontMatter); err != nil {
+		return false
+	}
+	return frontMatter.Id == videoId && frontMatter.Type != ""
+}
+
+// What ExportHugo did
+type HugoExportSummary struct {
+	Written   int // new or changed files
+	Unchanged int // already up to date, left alone
+	Removed   int // old copies from a section the video is no longer in
+}
+
+func (summary HugoExportSummary) String() string {
+	return fmt.Sprintf("%d written, %d unchanged, %d removed", summary.Written, summary.Unchanged, summary.Removed)
+}
+
+// ExportHugo writes contentDir/<section>/<VideoId>.md for every known video.
+// Files that already hold exactly what we would write are not touched, so Hugo
+// and rsync only see the videos that really changed.
+func ExportHugo(knownVideos KnownVideos, contentDir string) (HugoExportSummary, error) {
+	var summary HugoExportSummary
+
+	var videoIDs []string
+	for videoID := range knownVideos.Videos {
+		videoIDs = append(videoIDs, videoID)
+	}
+	sort.Strings(videoIDs)
+
+	if err := os.MkdirAll(contentDir, 0755); err != nil {
+		return summary, err
+	}
+	sections, err := hugoSections(contentDir)
+	if err != nil {
+		return summary, err
+	}
+
+	for _, videoID := range videoIDs {
+		video, _ := knownVideos.EffectiveVideo(videoID) // excluded only means excluded from totals
+		section := HugoSection(video.VideoType)
+		path := filepath.Join(contentDir, section, videoID+".md")
+
+		content, err := hugoContent(video)
+		if err != nil {
+			return summary, fmt.Errorf("exporting video %s: %w", videoID, err)
+		}
+
+		existing, err := os.ReadFile(path)
+		if err == nil && bytes.Equal(existing, content) {
+			summary.Unchanged++
+		} else {
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return summary, err
+			}
+			err = writeFileAtomically(path, func(w io.Writer) error {
+				_, err := w.Write(content)
+				return err
+			})
+			if err != nil {
+				return summary, fmt.Errorf("exporting video %s: %w", videoID, err)
+			}
+			summary.Written++
+		}
+
+		// a video whose type changed would otherwise show up in both sections.
+		// contentDir is the whole site, so only files export wrote are ours to remove.
+		for _, otherSection := range sections {
+			if otherSection == section {
+				continue
+			}
+			stale := filepath.Join(contentDir, otherSection, videoID+".md")
+			if !exportedHugoFile(stale, videoID) {
+				continue
+			}
+			if err := os.Remove(stale); err != nil {
+				return summary, err
+			}
+			summary.Removed++
+		}
+	}
+	return summary, nil
+}
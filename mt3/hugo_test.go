@@ -0,0 +1,43 @@
+package mt3
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportHugoOnlyRemovesItsOwnFiles(t *testing.T) {
+	contentDir := t.TempDir()
+	knownVideos := loadFixture(t, "v1.toml")
+	if _, err := ExportHugo(knownVideos, contentDir); err != nil {
+		t.Fatal(err)
+	}
+	// a post of the same name that export did not write
+	handWritten := filepath.Join(contentDir, "posts", "mt3video002.md")
+	if err := os.MkdirAll(filepath.Dir(handWritten), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(handWritten, []byte("+++\ntitle = \"About mt3video002\"\n+++\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	video := knownVideos.Videos["mt3video002"]
+	video.VideoType = Livestream
+	knownVideos.Videos["mt3video002"] = video
+	summary, err := ExportHugo(knownVideos, contentDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Written != 1 || summary.Removed != 1 {
+		t.Errorf("summary = %v, want the livestream written and the old snippet removed", summary)
+	}
+	if _, err := os.Stat(filepath.Join(contentDir, "snippets", "mt3video002.md")); !os.IsNotExist(err) {
+		t.Errorf("the old snippet copy is still there: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(contentDir, "livestreams", "mt3video002.md")); err != nil {
+		t.Errorf("the livestream copy was not written: %v", err)
+	}
+	if _, err := os.Stat(handWritten); err != nil {
+		t.Errorf("the hand written post was removed: %v", err)
+	}
+}
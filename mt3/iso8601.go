@@ -0,0 +1,135 @@
+package mt3
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// YouTube reports contentDetails.duration as an ISO 8601 duration, e.g.
+//    PT1H45M41S    normal video
+//    P1DT2H3M      livestreams longer than a day
+//    P0D           premieres and upcoming streams that have no length yet
+// time.ParseDuration does not understand days, weeks or the P0D form,
+// so we parse the whole thing here instead of cropping off the PT.
+// https://en.wikipedia.org/wiki/ISO_8601#Durations
+
+const maxDuration = time.Duration(1<<63 - 1)
+
+// DurationParseError is returned for anything that is not a duration we can use.
+// Input is kept so the caller can say which video had the weird value.
+type DurationParseError struct {
+	Input  string
+	Reason string
+}
+
+func (e *DurationParseError) Error() string {
+	return fmt.Sprintf("invalid ISO 8601 duration %q: %s", e.Input, e.Reason)
+}
+
+// ParseISO8601Duration converts something like P1DT2H3M4.5S into a time.Duration.
+// Years and months are rejected because their length depends on the calendar,
+// and YouTube never sends them anyway.
+func ParseISO8601Duration(input string) (time.Duration, error) {
+	fail := func(reason string) (time.Duration, error) {
+		return 0, &DurationParseError{Input: input, Reason: reason}
+	}
+
+	s := strings.TrimSpace(input)
+	if len(s) == 0 || (s[0] != 'P' && s[0] != 'p') {
+		return fail("must start with P")
+	}
+	s = strings.ToUpper(s[1:])
+
+	var total time.Duration
+	inTimePart := false // true after we pass the T separator
+	seenAny := false    // "P" and "PT" alone are not valid
+	lastUnit := ""      // units must appear in order, each at most once
+	order := "WDHMS"    // M means minutes here because months are rejected below
+
+	for len(s) > 0 {
+		if s[0] == 'T' {
+			if inTimePart {
+				return fail("more than one T")
+			}
+			inTimePart = true
+			s = s[1:]
+			if len(s) == 0 {
+				return fail("T must be followed by hours, minutes or seconds")
+			}
+			continue
+		}
+
+		// Read the number, which may have a fraction using . or ,
+		i := 0
+		for i < len(s) && (s[i] >= '0' && s[i] <= '9' || s[i] == '.' || s[i] == ',') {
+			i++
+		}
+		if i == 0 {
+			return fail(fmt.Sprintf("expected a number before %q", s[0]))
+		}
+		if i == len(s) {
+			return fail("number without a unit")
+		}
+		number := strings.Replace(s[:i], ",", ".", 1)
+		unit := string(s[i])
+		s = s[i+1:]
+
+		fractional := strings.Contains(number, ".")
+		if fractional && len(s) > 0 {
+			return fail("only the smallest unit may have a fraction")
+		}
+
+		var unitLength time.Duration
+		switch {
+		case unit == "Y":
+			return fail("years are not supported")
+		case unit == "M" && !inTimePart:
+			return fail("months are not supported")
+		case unit == "W" && !inTimePart:
+			unitLength = 7 * 24 * time.Hour
+		case unit == "D" && !inTimePart:
+			unitLength = 24 * time.Hour
+		case unit == "H" && inTimePart:
+			unitLength = time.Hour
+		case unit == "M" && inTimePart:
+			unitLength = time.Minute
+		case unit == "S" && inTimePart:
+			unitLength = time.Second
+		default:
+			return fail(fmt.Sprintf("unexpected unit %q", unit))
+		}
+
+		if lastUnit != "" && strings.Index(order, unit) <= strings.Index(order, lastUnit) {
+			return fail(fmt.Sprintf("unit %q is out of order", unit))
+		}
+		lastUnit = unit
+		seenAny = true
+
+		// Whole numbers are multiplied exactly; float64 is only used for fractions
+		var part time.Duration
+		if fractional {
+			value, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return fail(fmt.Sprintf("bad number %q", number))
+			}
+			if value*float64(unitLength) >= float64(maxDuration-total) {
+				return fail("too long to fit in a time.Duration")
+			}
+			part = time.Duration(value*float64(unitLength) + 0.5)
+		} else {
+			value, err := strconv.ParseInt(number, 10, 64)
+			if err != nil || value > int64((maxDuration-total)/unitLength) {
+				return fail("too long to fit in a time.Duration")
+			}
+			part = time.Duration(value) * unitLength
+		}
+		total += part
+	}
+
+	if !seenAny {
+		return fail("no duration components")
+	}
+	return total, nil
+}
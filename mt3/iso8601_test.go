@@ -0,0 +1,93 @@
+package mt3
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseISO8601Duration(t *testing.T) {
+	tests := []struct {
+		input string
+		want  time.Duration
+	}{
+		{"PT1H45M41S", time.Hour + 45*time.Minute + 41*time.Second},
+		{"PT15S", 15 * time.Second},
+		{"PT1M", time.Minute},
+		{"PT2H", 2 * time.Hour},
+		{"P0D", 0},
+		{"PT0S", 0},
+		{"P1D", 24 * time.Hour},
+		{"P1DT2H3M", 26*time.Hour + 3*time.Minute},
+		{"P1W", 7 * 24 * time.Hour},
+		{"P2W3DT4S", 17*24*time.Hour + 4*time.Second},
+		{"PT4.5S", 4*time.Second + 500*time.Millisecond},
+		{"PT4,25S", 4*time.Second + 250*time.Millisecond},
+		{"PT0.001S", time.Millisecond},
+		{"PT1.5M", 90 * time.Second},
+		{"P1DT1.5H", 25*time.Hour + 30*time.Minute},
+		{"pt1m30s", 90 * time.Second},
+		{" PT10S ", 10 * time.Second},
+		{"PT2562047H47M16S", 2562047*time.Hour + 47*time.Minute + 16*time.Second},
+	}
+	for _, test := range tests {
+		got, err := ParseISO8601Duration(test.input)
+		if err != nil {
+			t.Errorf("ParseISO8601Duration(%q) failed: %v", test.input, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseISO8601Duration(%q) = %v, want %v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseISO8601DurationErrors(t *testing.T) {
+	tests := []struct {
+		input  string
+		reason string
+	}{
+		{"", "must start with P"},
+		{"1H", "must start with P"},
+		{"T1H", "must start with P"},
+		{"P", "no duration components"},
+		{"PT", "T must be followed by hours, minutes or seconds"},
+		{"PT1HT2M", "more than one T"},
+		{"P1Y", "years are not supported"},
+		{"P1M", "months are not supported"},
+		{"PT1W", `unexpected unit "W"`},
+		{"P1H", `unexpected unit "H"`},
+		{"PT1D", `unexpected unit "D"`},
+		{"P1S", `unexpected unit "S"`},
+		{"PT1X", `unexpected unit "X"`},
+		{"PT10", "number without a unit"},
+		{"PTS", `expected a number before 'S'`},
+		{"PT1S1M", `unit "M" is out of order`},
+		{"PT1M1M", `unit "M" is out of order`},
+		{"P1D1W", `unit "W" is out of order`},
+		{"PT1.5M30S", "only the smallest unit may have a fraction"},
+		{"PT1.2.3S", `bad number "1.2.3"`},
+		{"PT9223372037S", "too long to fit in a time.Duration"},
+		{"PT2562048H", "too long to fit in a time.Duration"},
+		{"P99999999999999999999D", "too long to fit in a time.Duration"},
+		{"PT2562047H47M17S", "too long to fit in a time.Duration"},
+		{"PT9223372036.9S", "too long to fit in a time.Duration"},
+	}
+	for _, test := range tests {
+		got, err := ParseISO8601Duration(test.input)
+		var parseErr *DurationParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("ParseISO8601Duration(%q) = %v, %v; want a *DurationParseError", test.input, got, err)
+			continue
+		}
+		if parseErr.Input != test.input {
+			t.Errorf("ParseISO8601Duration(%q) error has Input %q", test.input, parseErr.Input)
+		}
+		if parseErr.Reason != test.reason {
+			t.Errorf("ParseISO8601Duration(%q) error reason %q, want %q", test.input, parseErr.Reason, test.reason)
+		}
+		if got != 0 {
+			t.Errorf("ParseISO8601Duration(%q) returned %v along with the error", test.input, got)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package mt3
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// normalizedVideo puts every time in UTC, and no tags as nil, so two copies of a video
+// loaded from different kinds of store compare equal when they mean the same thing
+func normalizedVideo(video VideoMeta) VideoMeta {
+	if len(video.Tags) == 0 {
+		video.Tags = nil
+	}
+	for _, t := range []*time.Time{&video.Published, &video.LiveActualStart, &video.LiveActualEnd, &video.LiveScheduledStart, &video.AvailabilityNoticed} {
+		if !t.IsZero() {
+			*t = t.UTC()
+		}
+	}
+	return video
+}
+
+// sameKnownVideos says whether a and b hold exactly the same videos and overrides
+func sameKnownVideos(a KnownVideos, b KnownVideos) error {
+	if len(a.Videos) != len(b.Videos) {
+		return fmt.Errorf("%d videos instead of %d", len(b.Videos), len(a.Videos))
+	}
+	for videoId, video := range a.Videos {
+		other, ok := b.Videos[videoId]
+		if !ok {
+			return fmt.Errorf("video %s is missing", videoId)
+		}
+		if !reflect.DeepEqual(normalizedVideo(video), normalizedVideo(other)) {
+			return fmt.Errorf("video %s is different: %v", videoId, diffVideoMeta(video, other))
+		}
+	}
+	if len(a.Overrides) != len(b.Overrides) {
+		return fmt.Errorf("%d overrides instead of %d", len(b.Overrides), len(a.Overrides))
+	}
+	for videoId, override := range a.Overrides {
+		if b.Overrides[videoId] != override {
+			return fmt.Errorf("override for %s is different", videoId)
+		}
+	}
+	return nil
+}
+
+// MigrateStore copies everything in from to to, then reads to back to make sure nothing was lost.
+// Whatever was in to is replaced (and backed up, keeping keepBackups).
+func MigrateStore(ctx context.Context, from Store, to Store, keepBackups int) (KnownVideos, error) {
+	knownVideos, err := from.Load(ctx)
+	if err != nil {
+		return knownVideos, fmt.Errorf("reading %s: %w", from.Path(), err)
+	}
+	if err := to.Save(ctx, knownVideos, keepBackups); err != nil {
+		return knownVideos, fmt.Errorf("writing %s: %w", to.Path(), err)
+	}
+	copied, err := to.Load(ctx)
+	if err != nil {
+		return knownVideos, fmt.Errorf("reading back %s: %w", to.Path(), err)
+	}
+	if err := sameKnownVideos(knownVideos, copied); err != nil {
+		return knownVideos, fmt.Errorf("%s does not match %s after migrating: %w", to.Path(), from.Path(), err)
+	}
+	return knownVideos, nil
+}
@@ -0,0 +1,54 @@
+package mt3
+
+import (
+	"fmt"
+)
+
+// VideoOverride is what I know better than YouTube or the rules file about one video.
+// Empty fields mean no override, so YouTube's title and the classified type show through.
+type VideoOverride struct {
+	Title     string       `toml:",omitempty" json:",omitempty" yaml:",omitempty"`
+	VideoType MT3VideoType `toml:",omitempty" json:",omitempty" yaml:",omitempty"` // forced type, whatever the rules say
+	Exclude   bool         `toml:",omitempty" json:",omitempty" yaml:",omitempty"` // leave it out of report totals, e.g. a trailer for another channel
+	Notes     string       `toml:",omitempty" json:",omitempty" yaml:",omitempty"`
+}
+
+// IsEmpty is true when the override no longer changes anything and can be dropped
+func (override VideoOverride) IsEmpty() bool {
+	return override == VideoOverride{}
+}
+
+// apply returns video as it should be shown, with override on top
+func (override VideoOverride) apply(video VideoMeta) VideoMeta {
+	if override.Title != "" {
+		video.Title = override.Title
+	}
+	if override.VideoType != "" {
+		video.VideoType = override.VideoType
+	}
+	return video
+}
+
+// EffectiveVideo is the video with videoId as reports and exports should see it: YouTube's data with my
+// override on top.  excluded says whether to leave it out of totals.
+func (knownVideos KnownVideos) EffectiveVideo(videoId string) (video VideoMeta, excluded bool) {
+	override := knownVideos.Overrides[videoId]
+	return override.apply(knownVideos.Videos[videoId]), override.Exclude
+}
+
+// SetOverride stores override for videoId, or drops it if it is empty.
+// Overrides are only allowed for videos we know about, to catch typos in the ID.
+func (knownVideos *KnownVideos) SetOverride(videoId string, override VideoOverride) error {
+	if _, exists := knownVideos.Videos[videoId]; !exists {
+		return fmt.Errorf("no known video with ID %s", videoId)
+	}
+	if override.IsEmpty() {
+		delete(knownVideos.Overrides, videoId)
+		return nil
+	}
+	if knownVideos.Overrides == nil {
+		knownVideos.Overrides = make(map[string]VideoOverride)
+	}
+	knownVideos.Overrides[videoId] = override
+	return nil
+}
@@ -0,0 +1,346 @@
+package mt3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// DefaultQuotaBudget is the daily quota YouTube gives a new project
+const DefaultQuotaBudget = 10000
+
+// quotaCosts is how many units each call costs, from
+// https://developers.google.com/youtube/v3/determine_quota_cost
+var quotaCosts = map[string]int{
+	"channels.list":      1,
+	"playlistItems.list": 1,
+	"playlists.list":     1,
+	"videos.list":        1,
+	"search.list":        100,
+	"videos.insert":      1600,
+}
+
+// quotaLedgerDays is how many days of usage the ledger keeps
+const quotaLedgerDays = 31
+
+// The quota resets at midnight Pacific time, so that is when our days start too
+var quotaTimeZone = loadQuotaTimeZone()
+
+func loadQuotaTimeZone() *time.Location {
+	location, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		return time.FixedZone("PST", -8*60*60) // no tzdata; an hour out in summer is close enough
+	}
+	return location
+}
+
+// QuotaDay is the quota day t falls in, e.g. 2019-04-12
+func QuotaDay(t time.Time) string {
+	return t.In(quotaTimeZone).Format("2006-01-02")
+}
+
+// QuotaUsage is what one kind of call used on one day
+type QuotaUsage struct {
+	Calls int `json:"calls"`
+	Units int `json:"units"`
+}
+
+// QuotaLedger is every call we have been charged for, by quota day and then by call, e.g. "search.list".
+// It lives in quota.json in the XDG data dir (see QuotaLedgerPath) and is saved after every charge,
+// so usage from runs that died part way still counts.  Every charge reads it again under a lock file
+// (see lockFile) before adding to it, so runs at the same time, e.g. a search during a long sync,
+// all count against the same budget.
+type QuotaLedger struct {
+	path string
+	Days map[string]map[string]QuotaUsage `json:"days"`
+}
+
+// LoadQuotaLedger reads the ledger at path.  A missing file is a ledger with nothing charged yet.
+func LoadQuotaLedger(path string) (*QuotaLedger, error) {
+	ledger := &QuotaLedger{path: path}
+	if err := ledger.reload(); err != nil {
+		return nil, err
+	}
+	return ledger, nil
+}
+
+// reload replaces what is in memory with what is in the file now, which other runs may have added to
+func (ledger *QuotaLedger) reload() error {
+	ledger.Days = nil
+	data, err := os.ReadFile(ledger.path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, ledger); err != nil {
+			return fmt.Errorf("reading quota ledger %s: %w", ledger.path, err)
+		}
+	}
+	if ledger.Days == nil {
+		ledger.Days = make(map[string]map[string]QuotaUsage)
+	}
+	return nil
+}
+
+// Used is how many units were charged on day
+func (ledger *QuotaLedger) Used(day string) int {
+	used := 0
+	for _, usage := range ledger.Days[day] {
+		used += usage.Units
+	}
+	return used
+}
+
+// Usage is what each kind of call used on day, sorted by call
+func (ledger *QuotaLedger) Usage(day string) ([]string, map[string]QuotaUsage) {
+	var calls []string
+	for call := range ledger.Days[day] {
+		calls = append(calls, call)
+	}
+	sort.Strings(calls)
+	return calls, ledger.Days[day]
+}
+
+// charge records one call on day and saves the ledger, dropping days older than quotaLedgerDays.
+// If budget is more than 0 and the call would take the day over it, nothing is charged
+// and the error is a *QuotaBudgetError.  The check and the charge are one step under the lock,
+// so two runs cannot both squeeze into the last few units.
+func (ledger *QuotaLedger) charge(day string, call string, units int, budget int) error {
+	if err := os.MkdirAll(filepath.Dir(ledger.path), 0755); err != nil {
+		return err
+	}
+	unlock, err := lockFile(ledger.path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := ledger.reload(); err != nil {
+		return err
+	}
+	if used := ledger.Used(day); budget > 0 && used+units > budget {
+		return &QuotaBudgetError{Call: call, Cost: units, Used: used, Budget: budget}
+	}
+
+	if ledger.Days[day] == nil {
+		ledger.Days[day] = make(map[string]QuotaUsage)
+	}
+	usage := ledger.Days[day][call]
+	usage.Calls++
+	usage.Units += units
+	ledger.Days[day][call] = usage
+
+	var days []string
+	for d := range ledger.Days {
+		days = append(days, d)
+	}
+	sort.Strings(days)
+	for len(days) > quotaLedgerDays {
+		delete(ledger.Days, days[0])
+		days = days[1:]
+	}
+	return ledger.save()
+}
+
+// How long lockFile waits for another run to let go, and how old a lock has to be before
+// it is taken to be left behind by a run that was killed.  A charge holds it for milliseconds.
+const (
+	lockWait     = 10 * time.Second
+	staleLockAge = time.Minute
+)
+
+// lockFile takes path.lock, creating it with O_EXCL so only one run can hold it, and returns how to let it go
+func lockFile(path string) (unlock func(), err error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(lockWait)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > staleLockAge {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s is held by another run; delete it if nothing else is running", lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (ledger *QuotaLedger) save() error {
+	if err := os.MkdirAll(filepath.Dir(ledger.path), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomically(ledger.path, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(ledger)
+	})
+}
+
+// QuotaBudgetError means a call was not made because it would have gone over our own daily budget.
+// YouTube was never asked, so nothing was charged for it.
+type QuotaBudgetError struct {
+	Call   string
+	Cost   int
+	Used   int
+	Budget int
+}
+
+func (e *QuotaBudgetError) Error() string {
+	return fmt.Sprintf("%s costs %d units but only %d of today's budget of %d are left", e.Call, e.Cost, e.Budget-e.Used, e.Budget)
+}
+
+// QuotaYouTube wraps another YouTubeAPI, charging every call to ledger and refusing any call
+// that would take today's usage over budget.  Put it inside RetryingYouTube so every retry is charged.
+type QuotaYouTube struct {
+	api    YouTubeAPI
+	ledger *QuotaLedger
+	budget int
+	now    func() time.Time
+}
+
+func NewQuotaYouTube(api YouTubeAPI, ledger *QuotaLedger, budget int) *QuotaYouTube {
+	return &QuotaYouTube{api: api, ledger: ledger, budget: budget, now: time.Now}
+}
+
+// charge checks call fits in the budget and records it, before it is made,
+// because YouTube charges for calls that fail too
+func (yt *QuotaYouTube) charge(call string) error {
+	err := yt.ledger.charge(QuotaDay(yt.now()), call, quotaCosts[call], yt.budget)
+	var budgetErr *QuotaBudgetError
+	if err != nil && !errors.As(err, &budgetErr) {
+		return fmt.Errorf("charging %s to the quota ledger: %w", call, err)
+	}
+	return err
+}
+
+func (yt *QuotaYouTube) ChannelsListMine(ctx context.Context, part string) (*youtube.ChannelListResponse, error) {
+	if err := yt.charge("channels.list"); err != nil {
+		return nil, err
+	}
+	return yt.api.ChannelsListMine(ctx, part)
+}
+
+func (yt *QuotaYouTube) PlaylistItemsList(ctx context.Context, part string, playlistId string, pageToken string, numItems int64) (*youtube.PlaylistItemListResponse, error) {
+	if err := yt.charge("playlistItems.list"); err != nil {
+		return nil, err
+	}
+	return yt.api.PlaylistItemsList(ctx, part, playlistId, pageToken, numItems)
+}
+
+func (yt *QuotaYouTube) VideosListMultipleIds(ctx context.Context, part string, id string) (*youtube.VideoListResponse, error) {
+	if err := yt.charge("videos.list"); err != nil {
+		return nil, err
+	}
+	return yt.api.VideosListMultipleIds(ctx, part, id)
+}
+
+func (yt *QuotaYouTube) PlaylistsList(ctx context.Context, part string, query PlaylistsQuery) (*youtube.PlaylistListResponse, error) {
+	if err := yt.charge("playlists.list"); err != nil {
+		return nil, err
+	}
+	return yt.api.PlaylistsList(ctx, part, query)
+}
+
+func (yt *QuotaYouTube) SearchList(ctx context.Context, part string, query string, maxResults int64) (*youtube.SearchListResponse, error) {
+	if err := yt.charge("search.list"); err != nil {
+		return nil, err
+	}
+	return yt.api.SearchList(ctx, part, query, maxResults)
+}
+
+func (yt *QuotaYouTube) VideosInsert(ctx context.Context, part string, video *youtube.Video, media io.Reader) (*youtube.Video, error) {
+	if err := yt.charge("videos.insert"); err != nil {
+		return nil, err
+	}
+	return yt.api.VideosInsert(ctx, part, video, media)
+}
+
+// QuotaEstimate is how many of each kind of call a command will make, e.g. {"videos.list": 3}.
+// The Estimate functions give the least the command can cost, so going over budget is certain, not a guess.
+type QuotaEstimate map[string]int
+
+// Units is what the calls cost altogether
+func (estimate QuotaEstimate) Units() int {
+	units := 0
+	for call, count := range estimate {
+		units += quotaCosts[call] * count
+	}
+	return units
+}
+
+func (estimate QuotaEstimate) String() string {
+	var calls []string
+	for call := range estimate {
+		calls = append(calls, call)
+	}
+	sort.Strings(calls)
+	var parts []string
+	for _, call := range calls {
+		parts = append(parts, fmt.Sprintf("%d %s", estimate[call], call))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// EstimateCall is one call on its own, e.g. search.list or videos.insert
+func EstimateCall(call string) QuotaEstimate {
+	return QuotaEstimate{call: 1}
+}
+
+// EstimateSync is LoadNewVideosFromMyChannel then FillInDurations: the channel, a page of uploads
+// for every 50 known videos on a full sync but only one on an incremental or resumed one,
+// and a videos.list batch for every 50 videos still missing a duration
+func EstimateSync(knownVideos KnownVideos, options SyncOptions) QuotaEstimate {
+	pages := 1
+	resuming := options.Resume != nil && options.Resume.PageToken != ""
+	if (options.FullSync || (options.Resume != nil && options.Resume.FullSync)) && !resuming {
+		pages = max(1, batchCount(len(knownVideos.Videos)))
+	}
+	estimate := EstimateDurations(knownVideos)
+	estimate["channels.list"] = 1
+	estimate["playlistItems.list"] = pages
+	return estimate
+}
+
+// EstimateDurations is FillInDurations: a videos.list batch for every 50 videos missing a duration
+func EstimateDurations(knownVideos KnownVideos) QuotaEstimate {
+	return QuotaEstimate{"videos.list": batchCount(len(videosWithEmptyDuration(&knownVideos)))}
+}
+
+// EstimateRefresh is RefreshVideos: a videos.list batch for every 50 videos filter matches
+func EstimateRefresh(knownVideos KnownVideos, filter RefreshFilter) QuotaEstimate {
+	return QuotaEstimate{"videos.list": batchCount(len(knownVideos.SortedVideoIds(filter.matches)))}
+}
+
+// batchCount is how many videos.list calls it takes to ask about videoCount videos
+func batchCount(videoCount int) int {
+	return (videoCount + MaxIdsPerVideosList - 1) / MaxIdsPerVideosList
+}
+
+// CheckEstimate is a *QuotaBudgetError if estimate does not fit in what is left of budget on the quota day of now.
+// Checking before starting means a command that cannot finish does not start, rather than stopping part way.
+func (ledger *QuotaLedger) CheckEstimate(now time.Time, command string, estimate QuotaEstimate, budget int) error {
+	if err := ledger.reload(); err != nil {
+		return err
+	}
+	used := ledger.Used(QuotaDay(now))
+	if cost := estimate.Units(); used+cost > budget {
+		return &QuotaBudgetError{Call: fmt.Sprintf("%s (%v)", command, estimate), Cost: cost, Used: used, Budget: budget}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package mt3
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQuotaEstimates(t *testing.T) {
+	knownVideos := syncedChannel(t, 120) // no durations yet
+	tests := []struct {
+		name     string
+		estimate QuotaEstimate
+		want     int
+	}{
+		{"full sync", EstimateSync(knownVideos, SyncOptions{FullSync: true}), 1 + 3 + 3},
+		{"incremental sync", EstimateSync(knownVideos, SyncOptions{}), 1 + 1 + 3},
+		{"resumed full sync", EstimateSync(knownVideos, SyncOptions{Resume: &SyncResume{PageToken: "100", FullSync: true}}), 1 + 1 + 3},
+		{"first sync", EstimateSync(KnownVideos{}, SyncOptions{}), 1 + 1},
+		{"durations", EstimateDurations(knownVideos), 3},
+		{"refresh", EstimateRefresh(knownVideos, RefreshFilter{PublishedAfter: firstUpload.AddDate(0, 0, 60)}), 2},
+		{"search", EstimateCall("search.list"), 100},
+		{"upload", EstimateCall("videos.insert"), 1600},
+	}
+	for _, test := range tests {
+		if got := test.estimate.Units(); got != test.want {
+			t.Errorf("%s: %v is %d units, want %d", test.name, test.estimate, got, test.want)
+		}
+	}
+}
+
+func TestCheckEstimate(t *testing.T) {
+	ledger, err := LoadQuotaLedger(filepath.Join(t.TempDir(), "quota.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	for i := 0; i < 9; i++ {
+		if err := ledger.charge(QuotaDay(now), "search.list", 100, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ledger.CheckEstimate(now, "search", EstimateCall("search.list"), 1000); err != nil {
+		t.Errorf("the last 100 units should fit: %v", err)
+	}
+	err = ledger.CheckEstimate(now, "upload", EstimateCall("videos.insert"), 1000)
+	var budgetErr *QuotaBudgetError
+	if !errors.As(err, &budgetErr) || budgetErr.Cost != 1600 || budgetErr.Used != 900 {
+		t.Errorf("upload with 100 units left: got %v, want a *QuotaBudgetError", err)
+	}
+}
+
+// Two runs charging the same ledger at the same time, e.g. a search during a long sync, must not lose each other's charges
+func TestQuotaLedgerSharedByRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	day := QuotaDay(time.Now())
+	var wg sync.WaitGroup
+	errs := make(chan error, 2)
+	for _, call := range []string{"videos.list", "search.list"} {
+		ledger, err := LoadQuotaLedger(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				if err := ledger.charge(day, call, 1, 0); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	ledger, err := LoadQuotaLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used := ledger.Used(day); used != 100 {
+		t.Errorf("%d units in the ledger, want both runs' 100", used)
+	}
+
+	// the budget is checked against what every run has charged, not what this one loaded
+	stale, err := LoadQuotaLedger(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ledger.charge(day, "videos.list", 5, 0); err != nil {
+		t.Fatal(err)
+	}
+	var budgetErr *QuotaBudgetError
+	if err := stale.charge(day, "videos.list", 1, 105); !errors.As(err, &budgetErr) || budgetErr.Used != 105 {
+		t.Errorf("charging over budget from a stale ledger: got %v, want a *QuotaBudgetError", err)
+	}
+}
@@ -0,0 +1,206 @@
+package mt3
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+// VideoAvailability says whether a known video can still be watched.  Empty means it can.
+type VideoAvailability string
+
+const (
+	VideoAvailable VideoAvailability = ""
+	VideoPrivate   VideoAvailability = "private"
+	VideoRemoved   VideoAvailability = "removed" // deleted or rejected, or no longer returned at all
+)
+
+// setAvailability changes video's availability, remembering when we noticed
+func setAvailability(video *VideoMeta, availability VideoAvailability) {
+	if video.Availability == availability {
+		return
+	}
+	video.Availability = availability
+	video.AvailabilityNoticed = time.Time{}
+	if availability != VideoAvailable {
+		video.AvailabilityNoticed = time.Now().UTC().Truncate(time.Second)
+	}
+}
+
+// recordAvailability reads the status part of a videos.list item
+func recordAvailability(video *VideoMeta, item *youtube.Video) {
+	if item.Status == nil {
+		return
+	}
+	switch {
+	case item.Status.UploadStatus == "deleted" || item.Status.UploadStatus == "rejected":
+		setAvailability(video, VideoRemoved)
+	case item.Status.PrivacyStatus == "private":
+		setAvailability(video, VideoPrivate)
+	default:
+		setAvailability(video, VideoAvailable)
+	}
+}
+
+// One field of one video that a refresh (or sync) changed
+type VideoChange struct {
+	VideoId string
+	Field   string
+	Old     string
+	New     string
+}
+
+func (change VideoChange) String() string {
+	return fmt.Sprintf("%s %s: %q -> %q", change.VideoId, change.Field, change.Old, change.New)
+}
+
+// diffVideoMeta lists the fields that differ between before and after, as text
+func diffVideoMeta(before VideoMeta, after VideoMeta) []VideoChange {
+	fields := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"Title", before.Title, after.Title},
+		{"Published", before.Published, after.Published},
+		{"Duration", before.Duration, after.Duration},
+		{"VideoType", before.VideoType, after.VideoType},
+		{"Tags", strings.Join(before.Tags, ", "), strings.Join(after.Tags, ", ")},
+		{"Description", before.Description, after.Description},
+		{"LiveChecked", before.LiveChecked, after.LiveChecked},
+		{"WasLive", before.WasLive, after.WasLive},
+		{"LiveActualStart", before.LiveActualStart, after.LiveActualStart},
+		{"LiveActualEnd", before.LiveActualEnd, after.LiveActualEnd},
+		{"LiveScheduledStart", before.LiveScheduledStart, after.LiveScheduledStart},
+		{"Availability", before.Availability, after.Availability},
+	}
+	var changes []VideoChange
+	for _, field := range fields {
+		oldText, newText := changeText(field.old), changeText(field.new)
+		if oldText != newText {
+			changes = append(changes, VideoChange{VideoId: after.VideoId, Field: field.name, Old: oldText, New: newText})
+		}
+	}
+	return changes
+}
+
+// changeText writes a field's value for a VideoChange; unset times and types are blank
+func changeText(value interface{}) string {
+	switch value := value.(type) {
+	case time.Time:
+		if value.IsZero() {
+			return ""
+		}
+		return value.UTC().Format(time.RFC3339)
+	case MT3VideoType:
+		return string(value)
+	}
+	return fmt.Sprint(value)
+}
+
+// RefreshFilter picks which known videos to refresh.  Zero values match everything.
+type RefreshFilter struct {
+	VideoIds        []string
+	VideoType       MT3VideoType
+	PublishedAfter  time.Time
+	PublishedBefore time.Time
+}
+
+func (filter RefreshFilter) matches(video VideoMeta) bool {
+	if len(filter.VideoIds) > 0 {
+		found := false
+		for _, videoId := range filter.VideoIds {
+			if videoId == video.VideoId {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if filter.VideoType != "" && video.VideoType != filter.VideoType {
+		return false
+	}
+	if !filter.PublishedAfter.IsZero() && video.Published.Before(filter.PublishedAfter) {
+		return false
+	}
+	if !filter.PublishedBefore.IsZero() && !video.Published.Before(filter.PublishedBefore) {
+		return false
+	}
+	return true
+}
+
+// What RefreshVideos found
+type RefreshSummary struct {
+	Checked     int
+	Changed     int // videos with at least one change
+	Unavailable int // videos that are now private or removed
+}
+
+func (summary RefreshSummary) String() string {
+	return fmt.Sprintf("%d checked, %d changed, %d private or removed", summary.Checked, summary.Changed, summary.Unavailable)
+}
+
+// RefreshVideos asks YouTube again about every known video filter matches, 50 at a time,
+// even the ones that already have a Duration, so title edits, new durations and
+// privacy changes are picked up.  Videos that do not come back at all are marked VideoRemoved.
+// checkpoint, if set, is called after each batch so progress can be saved.  Running out of quota
+// stops at the batch boundary without an error, like FillInDurations; the rest are refreshed next time.
+// If a call fails, or ctx is cancelled, the error comes back with everything changed by the batches before it.
+func RefreshVideos(ctx context.Context, api YouTubeAPI, knownVideos *KnownVideos, filter RefreshFilter, classifier *Classifier, checkpoint func() error) (RefreshSummary, []VideoChange, error) {
+	var summary RefreshSummary
+	var changes []VideoChange
+
+	videoIDs := knownVideos.SortedVideoIds(filter.matches)
+	batches := chunkVideoIDs(videoIDs, MaxIdsPerVideosList)
+	fmt.Printf("Refreshing %d videos in %d batches\r\n", len(videoIDs), len(batches))
+
+	for batchNumber, batch := range batches {
+		response, err := api.VideosListMultipleIds(ctx, videoDetailsParts, batch)
+		if IsAPIError(err, APIErrorQuotaExceeded) {
+			fmt.Printf("Out of quota after %d of %d batches, the rest will be refreshed next time: %v\r\n", batchNumber, len(batches), err)
+			return summary, changes, nil
+		}
+		if err != nil {
+			return summary, changes, fmt.Errorf("refreshing batch %d/%d starting with video %s: %w", batchNumber+1, len(batches), strings.SplitN(batch, ",", 2)[0], err)
+		}
+
+		returned := make(map[string]*youtube.Video)
+		for _, item := range response.Items {
+			returned[item.Id] = item
+		}
+		for _, videoId := range strings.Split(batch, ",") {
+			before := knownVideos.Videos[videoId]
+			after := before
+			if item, ok := returned[videoId]; ok {
+				if err := applyVideoDetails(&after, item, classifier); err != nil {
+					fmt.Printf("Skipping video %s: %v\r\n", videoId, err)
+					continue
+				}
+			} else {
+				// YouTube leaves out deleted videos, and private ones that are not ours
+				setAvailability(&after, VideoRemoved)
+			}
+			summary.Checked++
+
+			videoChanges := diffVideoMeta(before, after)
+			if len(videoChanges) > 0 {
+				summary.Changed++
+				changes = append(changes, videoChanges...)
+				knownVideos.Videos[videoId] = after
+			}
+			if after.Availability != VideoAvailable {
+				summary.Unavailable++
+			}
+		}
+		fmt.Printf("Batch %d/%d done, %d videos changed so far\r\n", batchNumber+1, len(batches), summary.Changed)
+		if checkpoint != nil {
+			if err := checkpoint(); err != nil {
+				return summary, changes, fmt.Errorf("saving progress after refresh batch %d/%d: %w", batchNumber+1, len(batches), err)
+			}
+		}
+	}
+	return summary, changes, nil
+}
@@ -0,0 +1,62 @@
+package mt3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// refreshUntil refreshes a synced 120 video channel, making every call after the first batch fail with err
+func refreshUntil(t *testing.T, err error) (KnownVideos, RefreshSummary, []VideoChange, int, error) {
+	t.Helper()
+	knownVideos := syncedChannel(t, 120)
+	fake := fakeChannel(120)
+	checkpoints := 0
+	summary, changes, refreshErr := RefreshVideos(context.Background(), fake, &knownVideos, RefreshFilter{}, DefaultClassifier(), func() error {
+		checkpoints++
+		fake.Errors["VideosListMultipleIds"] = err
+		return nil
+	})
+	return knownVideos, summary, changes, checkpoints, refreshErr
+}
+
+func TestRefreshVideosOutOfQuota(t *testing.T) {
+	quotaErr := &APIError{Call: "videos.list", Kind: APIErrorQuotaExceeded, Attempts: 1, Err: fmt.Errorf("quotaExceeded")}
+	knownVideos, summary, changes, checkpoints, err := refreshUntil(t, quotaErr)
+	if err != nil {
+		t.Fatalf("running out of quota should just stop, got %v", err)
+	}
+	if summary.Checked != 50 || summary.Changed != 50 || checkpoints != 1 {
+		t.Errorf("summary = %v after %d checkpoints, want the first batch of 50 and its checkpoint", summary, checkpoints)
+	}
+	if got := knownVideos.Videos["vid000"]; got.Duration == 0 || !got.LiveChecked {
+		t.Errorf("vid000 from the first batch = %+v, want it refreshed", got)
+	}
+	if got := knownVideos.Videos["vid119"]; got.Duration != 0 {
+		t.Errorf("vid119 has duration %v, but its batch ran out of quota", got.Duration)
+	}
+	liveChecked := 0
+	for _, change := range changes {
+		if change.Field == "LiveChecked" {
+			liveChecked++
+		}
+	}
+	if liveChecked != 50 {
+		t.Errorf("%d LiveChecked changes, want one for each refreshed video", liveChecked)
+	}
+}
+
+func TestRefreshVideosAPIError(t *testing.T) {
+	apiErr := errors.New("backend error")
+	knownVideos, summary, changes, _, err := refreshUntil(t, apiErr)
+	if !errors.Is(err, apiErr) {
+		t.Fatalf("err = %v, want the API error", err)
+	}
+	if summary.Checked != 50 || len(changes) == 0 {
+		t.Errorf("summary = %v with %d changes, want the first batch kept", summary, len(changes))
+	}
+	if knownVideos.Videos["vid000"].Duration == 0 {
+		t.Error("the first batch was thrown away")
+	}
+}
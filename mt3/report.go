@@ -0,0 +1,180 @@
+package mt3
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// How BuildReport groups videos by their Published date
+type ReportPeriod uint8
+
+const (
+	ByDay ReportPeriod = iota
+	ByWeek
+	ByMonth
+	ByYear
+)
+
+var reportPeriodNames = map[string]ReportPeriod{"day": ByDay, "week": ByWeek, "month": ByMonth, "year": ByYear}
+
+// ParseReportPeriod turns "day", "week", "month" or "year" into a ReportPeriod
+func ParseReportPeriod(name string) (ReportPeriod, error) {
+	period, ok := reportPeriodNames[name]
+	if !ok {
+		return ByMonth, fmt.Errorf("unknown report period %q, want day, week, month or year", name)
+	}
+	return period, nil
+}
+
+func (period ReportPeriod) String() string {
+	for name, p := range reportPeriodNames {
+		if p == period {
+			return name
+		}
+	}
+	return fmt.Sprintf("ReportPeriod(%d)", uint8(period))
+}
+
+// periodKey names the day, ISO week, month or year that published falls in.
+// The keys sort in date order as plain strings.
+func periodKey(period ReportPeriod, published time.Time) string {
+	published = published.UTC()
+	switch period {
+	case ByDay:
+		return published.Format("2006-01-02")
+	case ByWeek:
+		year, week := published.ISOWeek()
+		return fmt.Sprintf("%04d-W%02d", year, week)
+	case ByYear:
+		return published.Format("2006")
+	}
+	return published.Format("2006-01")
+}
+
+// One line of a report: the videos whose type or period is Group
+// Average only counts videos that have a Duration; NoDuration says how many do not (yet)
+type ReportRow struct {
+	Group      string
+	Videos     int
+	Total      time.Duration
+	Average    time.Duration
+	NoDuration int
+}
+
+func (row *ReportRow) add(video VideoMeta) {
+	row.Videos++
+	row.Total += video.Duration
+	if video.Duration == 0 {
+		row.NoDuration++
+	}
+}
+
+func (row *ReportRow) finish() {
+	if timed := row.Videos - row.NoDuration; timed > 0 {
+		row.Average = row.Total / time.Duration(timed)
+	}
+}
+
+// Report is how long I have spent on Marble Track 3, in total, per MT3VideoType and per Period
+// Videos with an Exclude override are only counted in Excluded, and
+// private or removed ones only in Unavailable unless BuildReport was asked to include them
+type Report struct {
+	Period      ReportPeriod
+	Excluded    int
+	Unavailable int
+	Total       ReportRow
+	ByType      []ReportRow
+	ByPeriod    []ReportRow
+}
+
+// reportTypes is the order types are listed in; every one gets a row even with no videos
+var reportTypes = []MT3VideoType{Livestream, Snippet, Unknown}
+
+// BuildReport adds up the durations of knownVideos by type and by period.
+// Time spent on videos that are now private or removed still happened, so includeUnavailable can count them.
+func BuildReport(knownVideos KnownVideos, period ReportPeriod, includeUnavailable bool) Report {
+	report := Report{Period: period, Total: ReportRow{Group: "All"}}
+
+	byType := make(map[MT3VideoType]*ReportRow)
+	for _, videoType := range reportTypes {
+		byType[videoType] = &ReportRow{Group: videoType.String()}
+	}
+	byPeriod := make(map[string]*ReportRow)
+
+	for videoId := range knownVideos.Videos {
+		video, excluded := knownVideos.EffectiveVideo(videoId)
+		if excluded {
+			report.Excluded++
+			continue
+		}
+		if video.Availability != VideoAvailable {
+			report.Unavailable++
+			if !includeUnavailable {
+				continue
+			}
+		}
+		report.Total.add(video)
+
+		videoType := MT3VideoType(video.VideoType.String()) // an empty type is Unknown
+		typeRow, ok := byType[videoType]
+		if !ok {
+			typeRow = &ReportRow{Group: videoType.String()}
+			byType[videoType] = typeRow
+		}
+		typeRow.add(video)
+
+		key := periodKey(period, video.Published)
+		periodRow, ok := byPeriod[key]
+		if !ok {
+			periodRow = &ReportRow{Group: key}
+			byPeriod[key] = periodRow
+		}
+		periodRow.add(video)
+	}
+
+	report.Total.finish()
+	// reportTypes first, in their order, then types from the rules file by name
+	for _, videoType := range reportTypes {
+		row := byType[videoType]
+		row.finish()
+		report.ByType = append(report.ByType, *row)
+		delete(byType, videoType)
+	}
+	var others []MT3VideoType
+	for videoType := range byType {
+		others = append(others, videoType)
+	}
+	sort.Slice(others, func(i, j int) bool { return others[i] < others[j] })
+	for _, videoType := range others {
+		byType[videoType].finish()
+		report.ByType = append(report.ByType, *byType[videoType])
+	}
+
+	for _, row := range byPeriod {
+		row.finish()
+		report.ByPeriod = append(report.ByPeriod, *row)
+	}
+	sort.Slice(report.ByPeriod, func(i, j int) bool {
+		return report.ByPeriod[i].Group < report.ByPeriod[j].Group
+	})
+	return report
+}
+
+// UnavailableVideos lists the known videos that are private or removed, most recently noticed first
+func UnavailableVideos(knownVideos KnownVideos) []VideoMeta {
+	var videos []VideoMeta
+	for videoId := range knownVideos.Videos {
+		video, _ := knownVideos.EffectiveVideo(videoId)
+		if video.Availability != VideoAvailable {
+			videos = append(videos, video)
+		}
+	}
+	sort.Slice(videos, func(i, j int) bool {
+		if !videos[i].AvailabilityNoticed.Equal(videos[j].AvailabilityNoticed) {
+			return videos[i].AvailabilityNoticed.After(videos[j].AvailabilityNoticed)
+		}
+		return videos[i].VideoId < videos[j].VideoId
+	})
+	return videos
+}
@@ -0,0 +1,258 @@
+package mt3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+// APIErrorKind says what went wrong with an API call, and so whether trying again could help
+type APIErrorKind int
+
+const (
+	APIErrorOther         APIErrorKind = iota
+	APIErrorRetryable                  // 5xx, 429, rate limits and network trouble: worth another go after a pause
+	APIErrorQuotaExceeded              // the project's daily quota is used up, so nothing will work until it resets
+	APIErrorForbidden                  // not allowed, e.g. the wrong scope or someone else's video
+	APIErrorNotFound
+)
+
+func (kind APIErrorKind) String() string {
+	switch kind {
+	case APIErrorRetryable:
+		return "temporary failure"
+	case APIErrorQuotaExceeded:
+		return "quota exceeded"
+	case APIErrorForbidden:
+		return "forbidden"
+	case APIErrorNotFound:
+		return "not found"
+	}
+	return "error"
+}
+
+// APIError is what RetryingYouTube returns when a call fails for good.
+// Use errors.As to get at it, and Err (or errors.As again) for the *googleapi.Error underneath.
+type APIError struct {
+	Call     string // e.g. "videos.list"
+	Kind     APIErrorKind
+	Attempts int
+	Err      error
+}
+
+func (e *APIError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%s: %s after %d attempts: %v", e.Call, e.Kind, e.Attempts, e.Err)
+	}
+	return fmt.Sprintf("%s: %s: %v", e.Call, e.Kind, e.Err)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// IsAPIError says whether err is an *APIError of the given kind
+func IsAPIError(err error, kind APIErrorKind) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Kind == kind
+}
+
+// The reasons in googleapi.Error.Errors that YouTube uses for quota and rate limits.
+// https://developers.google.com/youtube/v3/docs/errors
+var quotaReasons = map[string]bool{"quotaExceeded": true, "dailyLimitExceeded": true}
+var rateLimitReasons = map[string]bool{"rateLimitExceeded": true, "userRateLimitExceeded": true}
+
+// ClassifyAPIError sorts an error from a YouTube Data API call into an APIErrorKind
+func ClassifyAPIError(err error) APIErrorKind {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Kind
+	}
+	var budgetErr *QuotaBudgetError
+	if errors.As(err, &budgetErr) {
+		return APIErrorQuotaExceeded // our own budget, but just as final for today
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return APIErrorOther // we gave up on it, so trying again would be wrong (it would also look like a net.Error)
+	}
+	var googleErr *googleapi.Error
+	if errors.As(err, &googleErr) {
+		for _, item := range googleErr.Errors {
+			if quotaReasons[item.Reason] {
+				return APIErrorQuotaExceeded
+			}
+			if rateLimitReasons[item.Reason] {
+				return APIErrorRetryable
+			}
+		}
+		switch {
+		case googleErr.Code == http.StatusTooManyRequests, googleErr.Code >= 500:
+			return APIErrorRetryable
+		case googleErr.Code == http.StatusForbidden, googleErr.Code == http.StatusUnauthorized:
+			return APIErrorForbidden
+		case googleErr.Code == http.StatusNotFound:
+			return APIErrorNotFound
+		}
+		return APIErrorOther
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return APIErrorRetryable
+	}
+	return APIErrorOther
+}
+
+// RetryPolicy is how hard RetryingYouTube tries.  The pause before attempt n+1 is a random
+// fraction of BaseDelay * 2^(n-1), capped at MaxDelay, so parallel runs do not retry in step.
+type RetryPolicy struct {
+	MaxAttempts int // including the first; 1 never retries
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	// Sleep and Jitter are time.Sleep and rand.Float64 unless set, e.g. to make a fake instant.
+	// The default sleep is cut short if the call's ctx is cancelled.
+	Sleep  func(time.Duration)
+	Jitter func() float64
+}
+
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 32 * time.Second}
+
+// delay is how long to wait after attempt (counting from 1) failed
+func (policy RetryPolicy) delay(attempt int) time.Duration {
+	backoff := policy.BaseDelay << uint(attempt-1)
+	if backoff > policy.MaxDelay || backoff <= 0 {
+		backoff = policy.MaxDelay
+	}
+	jitter := rand.Float64
+	if policy.Jitter != nil {
+		jitter = policy.Jitter
+	}
+	return time.Duration(jitter() * float64(backoff))
+}
+
+// RetryingYouTube wraps another YouTubeAPI, trying retryable failures again with backoff.
+// Every error it returns is an *APIError, so callers can tell a used up quota from a missing video.
+type RetryingYouTube struct {
+	api    YouTubeAPI
+	policy RetryPolicy
+}
+
+func NewRetryingYouTube(api YouTubeAPI, policy RetryPolicy) *RetryingYouTube {
+	return &RetryingYouTube{api: api, policy: policy}
+}
+
+// sleepContext waits for delay, or until ctx is cancelled, in which case it returns ctx.Err()
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// retry runs do until it succeeds, fails in a way retrying cannot fix, runs out of attempts
+// or ctx is cancelled while it waits to try again
+func (yt *RetryingYouTube) retry(ctx context.Context, call string, do func() error) error {
+	sleep := func(delay time.Duration) error {
+		return sleepContext(ctx, delay)
+	}
+	if yt.policy.Sleep != nil {
+		sleep = func(delay time.Duration) error {
+			yt.policy.Sleep(delay)
+			return ctx.Err()
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		err := do()
+		if err == nil {
+			return nil
+		}
+		kind := ClassifyAPIError(err)
+		if kind != APIErrorRetryable || attempt >= yt.policy.MaxAttempts {
+			return &APIError{Call: call, Kind: kind, Attempts: attempt, Err: err}
+		}
+		delay := yt.policy.delay(attempt)
+		fmt.Printf("%s failed (%v), trying again in %s\r\n", call, err, delay.Round(time.Millisecond))
+		if err := sleep(delay); err != nil {
+			return &APIError{Call: call, Kind: APIErrorOther, Attempts: attempt, Err: err}
+		}
+	}
+}
+
+func (yt *RetryingYouTube) ChannelsListMine(ctx context.Context, part string) (response *youtube.ChannelListResponse, err error) {
+	err = yt.retry(ctx, "channels.list", func() error {
+		response, err = yt.api.ChannelsListMine(ctx, part)
+		return err
+	})
+	return response, err
+}
+
+func (yt *RetryingYouTube) PlaylistItemsList(ctx context.Context, part string, playlistId string, pageToken string, numItems int64) (response *youtube.PlaylistItemListResponse, err error) {
+	err = yt.retry(ctx, "playlistItems.list", func() error {
+		response, err = yt.api.PlaylistItemsList(ctx, part, playlistId, pageToken, numItems)
+		return err
+	})
+	return response, err
+}
+
+func (yt *RetryingYouTube) VideosListMultipleIds(ctx context.Context, part string, id string) (response *youtube.VideoListResponse, err error) {
+	err = yt.retry(ctx, "videos.list", func() error {
+		response, err = yt.api.VideosListMultipleIds(ctx, part, id)
+		return err
+	})
+	return response, err
+}
+
+func (yt *RetryingYouTube) PlaylistsList(ctx context.Context, part string, query PlaylistsQuery) (response *youtube.PlaylistListResponse, err error) {
+	err = yt.retry(ctx, "playlists.list", func() error {
+		response, err = yt.api.PlaylistsList(ctx, part, query)
+		return err
+	})
+	return response, err
+}
+
+func (yt *RetryingYouTube) SearchList(ctx context.Context, part string, query string, maxResults int64) (response *youtube.SearchListResponse, err error) {
+	err = yt.retry(ctx, "search.list", func() error {
+		response, err = yt.api.SearchList(ctx, part, query, maxResults)
+		return err
+	})
+	return response, err
+}
+
+// VideosInsert only retries if it can rewind media, e.g. an *os.File; otherwise one go is all it gets
+func (yt *RetryingYouTube) VideosInsert(ctx context.Context, part string, video *youtube.Video, media io.Reader) (response *youtube.Video, err error) {
+	retrier := yt
+	seeker, canRewind := media.(io.Seeker)
+	var start int64
+	if canRewind {
+		if start, err = seeker.Seek(0, io.SeekCurrent); err != nil {
+			canRewind = false
+		}
+	}
+	if !canRewind {
+		retrier = &RetryingYouTube{api: yt.api, policy: yt.policy}
+		retrier.policy.MaxAttempts = 1
+	}
+	first := true
+	err = retrier.retry(ctx, "videos.insert", func() error {
+		if !first {
+			if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+				return err
+			}
+		}
+		first = false
+		response, err = yt.api.VideosInsert(ctx, part, video, media)
+		return err
+	})
+	return response, err
+}
@@ -0,0 +1,195 @@
+package mt3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+// googleError is what the client library returns for an HTTP error with reason in its body
+func googleError(code int, reason string) *googleapi.Error {
+	googleErr := &googleapi.Error{Code: code, Message: http.StatusText(code)}
+	if reason != "" {
+		googleErr.Errors = []googleapi.ErrorItem{{Reason: reason}}
+	}
+	return googleErr
+}
+
+func TestClassifyAPIError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want APIErrorKind
+	}{
+		{"500", googleError(500, ""), APIErrorRetryable},
+		{"503 backendError", googleError(503, "backendError"), APIErrorRetryable},
+		{"429", googleError(429, ""), APIErrorRetryable},
+		{"403 rateLimitExceeded", googleError(403, "rateLimitExceeded"), APIErrorRetryable},
+		{"403 userRateLimitExceeded", googleError(403, "userRateLimitExceeded"), APIErrorRetryable},
+		{"403 quotaExceeded", googleError(403, "quotaExceeded"), APIErrorQuotaExceeded},
+		{"403 dailyLimitExceeded", googleError(403, "dailyLimitExceeded"), APIErrorQuotaExceeded},
+		{"403 forbidden", googleError(403, "forbidden"), APIErrorForbidden},
+		{"401", googleError(401, "authError"), APIErrorForbidden},
+		{"404", googleError(404, "videoNotFound"), APIErrorNotFound},
+		{"400", googleError(400, "invalidPageToken"), APIErrorOther},
+		{"wrapped 503", fmt.Errorf("listing: %w", googleError(503, "")), APIErrorRetryable},
+		{"our own budget", &QuotaBudgetError{Call: "search.list", Cost: 100, Used: 9950, Budget: 10000}, APIErrorQuotaExceeded},
+		{"already classified", &APIError{Call: "videos.list", Kind: APIErrorNotFound, Err: errors.New("gone")}, APIErrorNotFound},
+		{"cancelled", fmt.Errorf("waiting: %w", context.Canceled), APIErrorOther},
+		{"deadline", context.DeadlineExceeded, APIErrorOther},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}, APIErrorRetryable},
+		{"cut short", io.ErrUnexpectedEOF, APIErrorRetryable},
+		{"anything else", errors.New("no idea"), APIErrorOther},
+	}
+	for _, test := range tests {
+		if got := ClassifyAPIError(test.err); got != test.want {
+			t.Errorf("%s: ClassifyAPIError(%v) = %s, want %s", test.name, test.err, got, test.want)
+		}
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{BaseDelay: time.Second, MaxDelay: 32 * time.Second, Jitter: func() float64 { return 1 }}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{6, 32 * time.Second},
+		{7, 32 * time.Second},
+		{100, 32 * time.Second}, // the shift overflows
+	}
+	for _, test := range tests {
+		if got := policy.delay(test.attempt); got != test.want {
+			t.Errorf("delay(%d) = %v, want %v", test.attempt, got, test.want)
+		}
+	}
+	policy.Jitter = func() float64 { return 0.25 }
+	if got := policy.delay(3); got != time.Second {
+		t.Errorf("delay(3) with a quarter jitter = %v, want 1s", got)
+	}
+}
+
+// retryingFake is a RetryingYouTube around fake that never really sleeps, and the pauses it would have taken
+func retryingFake(fake *FakeYouTube, maxAttempts int) (*RetryingYouTube, *[]time.Duration) {
+	var sleeps []time.Duration
+	policy := RetryPolicy{
+		MaxAttempts: maxAttempts,
+		BaseDelay:   time.Second,
+		MaxDelay:    32 * time.Second,
+		Sleep:       func(delay time.Duration) { sleeps = append(sleeps, delay) },
+		Jitter:      func() float64 { return 1 },
+	}
+	return NewRetryingYouTube(fake, policy), &sleeps
+}
+
+func TestRetryingYouTube(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		failures   int
+		wantCalls  int
+		wantSleeps string
+		wantKind   APIErrorKind // of the error that comes back; -1 for none
+	}{
+		{"recovers", googleError(503, "backendError"), 2, 3, "[1s 2s]", -1},
+		{"gives up", googleError(500, ""), 5, 3, "[1s 2s]", APIErrorRetryable},
+		{"rate limited", googleError(403, "rateLimitExceeded"), 1, 2, "[1s]", -1},
+		{"out of quota", googleError(403, "quotaExceeded"), 5, 1, "[]", APIErrorQuotaExceeded},
+		{"not found", googleError(404, "videoNotFound"), 5, 1, "[]", APIErrorNotFound},
+	}
+	for _, test := range tests {
+		fake := fakeChannel(3)
+		fake.Errors["VideosListMultipleIds"] = test.err
+		fake.ErrorsLeft["VideosListMultipleIds"] = test.failures
+		api, sleeps := retryingFake(fake, 3)
+
+		response, err := api.VideosListMultipleIds(context.Background(), "contentDetails", "vid000,vid001")
+		if calls := len(callsTo(fake, "VideosListMultipleIds")); calls != test.wantCalls {
+			t.Errorf("%s: %d calls, want %d", test.name, calls, test.wantCalls)
+		}
+		if got := fmt.Sprint(*sleeps); got != test.wantSleeps {
+			t.Errorf("%s: slept %s, want %s", test.name, got, test.wantSleeps)
+		}
+		if test.wantKind < 0 {
+			if err != nil || len(response.Items) != 2 {
+				t.Errorf("%s: got %v, want both videos", test.name, err)
+			}
+			continue
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Kind != test.wantKind || apiErr.Attempts != test.wantCalls {
+			t.Errorf("%s: got %v, want an *APIError of kind %s after %d attempts", test.name, err, test.wantKind, test.wantCalls)
+		}
+		if !errors.Is(err, test.err) {
+			t.Errorf("%s: %v does not wrap the googleapi.Error", test.name, err)
+		}
+	}
+}
+
+func TestRetryingYouTubeCancelled(t *testing.T) {
+	fake := fakeChannel(3)
+	fake.Errors["VideosListMultipleIds"] = googleError(503, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	api := NewRetryingYouTube(fake, RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: time.Second, Sleep: func(time.Duration) { cancel() }})
+
+	_, err := api.VideosListMultipleIds(ctx, "contentDetails", "vid000")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if calls := len(callsTo(fake, "VideosListMultipleIds")); calls != 1 {
+		t.Errorf("%d calls, want none after being cancelled", calls)
+	}
+
+	// the default sleep is cut short too
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	api = NewRetryingYouTube(fakeChannel(3), RetryPolicy{MaxAttempts: 5, BaseDelay: time.Hour, MaxDelay: time.Hour})
+	if err := api.retry(ctx, "videos.list", func() error { return googleError(503, "") }); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled without waiting an hour", err)
+	}
+}
+
+func TestRetryingYouTubeUploadRewinds(t *testing.T) {
+	const video = "not really a video"
+	upload := func(media io.Reader) (*FakeYouTube, *youtube.Video, error) {
+		fake := fakeChannel(0)
+		fake.Errors["VideosInsert"] = googleError(503, "")
+		fake.ErrorsLeft["VideosInsert"] = 1
+		api, _ := retryingFake(fake, 3)
+		uploaded, err := api.VideosInsert(context.Background(), "snippet", &youtube.Video{}, media)
+		return fake, uploaded, err
+	}
+
+	// a file can be read again from the start
+	fake, uploaded, err := upload(strings.NewReader(video))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := len(callsTo(fake, "VideosInsert")); calls != 2 {
+		t.Errorf("%d upload attempts, want 2", calls)
+	}
+	if uploaded.FileDetails.FileSize != uint64(len(video)) {
+		t.Errorf("the retry uploaded %d bytes, want all %d", uploaded.FileDetails.FileSize, len(video))
+	}
+
+	// a pipe cannot, so it only gets one go
+	fake, _, err = upload(io.MultiReader(strings.NewReader(video)))
+	if !IsAPIError(err, APIErrorRetryable) {
+		t.Errorf("got %v, want the 503 back", err)
+	}
+	if calls := len(callsTo(fake, "VideosInsert")); calls != 1 {
+		t.Errorf("%d upload attempts of an unseekable reader, want 1", calls)
+	}
+}
@@ -0,0 +1,156 @@
+package mt3
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CurrentSchemaVersion is the layout of KnownVideos this build writes, saved in the store
+// as SchemaVersion (or PRAGMA user_version for SQLite).  A store without one is version 0.
+// Change the layout by bumping this and adding a schemaMigration that brings older stores up to it.
+const CurrentSchemaVersion = 1
+
+// schemaVersionKey is what the one-file stores call it.  yaml.v3 lowercases it, like every other key.
+const schemaVersionKey = "SchemaVersion"
+
+// A schemaMigration brings a store from Version-1 up to Version.
+// It works on the store as decoded into plain maps, before any of it is decoded into a VideoMeta,
+// so it can fix up values the current structs would choke on.
+type schemaMigration struct {
+	Version  int
+	Describe string
+	Migrate  func(store map[string]interface{}) error
+}
+
+// schemaMigrations are run in order, from the one after the store's version up to CurrentSchemaVersion
+var schemaMigrations = []schemaMigration{
+	{1, "video types are names instead of the old enum's numbers", migrateLegacyVideoTypes},
+}
+
+// StoreSchemaError means the store was written by a newer version of this program,
+// which may have changed the layout in ways we would throw away by saving
+type StoreSchemaError struct {
+	Path    string
+	Version int
+}
+
+func (e *StoreSchemaError) Error() string {
+	return fmt.Sprintf("%s has schema version %d, but this build only understands up to %d", e.Path, e.Version, CurrentSchemaVersion)
+}
+
+// rawField looks key up ignoring case, because yaml.v3 lowercases every field name
+func rawField(raw map[string]interface{}, key string) (string, interface{}, bool) {
+	for name, value := range raw {
+		if strings.EqualFold(name, key) {
+			return name, value, true
+		}
+	}
+	return key, nil, false
+}
+
+// rawInt reads a number however the decoder handed it to us
+func rawInt(value interface{}) (int64, bool) {
+	switch n := value.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint64:
+		return int64(n), true
+	case float64:
+		return int64(n), float64(int64(n)) == n
+	case json.Number:
+		i, err := n.Int64()
+		return i, err == nil
+	case string: // an old store that quoted it
+		i, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// rawSchemaVersion is the store's SchemaVersion, or 0 if it does not have one
+func rawSchemaVersion(raw map[string]interface{}) (int, error) {
+	_, value, ok := rawField(raw, schemaVersionKey)
+	if !ok {
+		return 0, nil
+	}
+	version, ok := rawInt(value)
+	if !ok || version < 0 {
+		return 0, fmt.Errorf("%s %v is not a version number", schemaVersionKey, value)
+	}
+	return int(version), nil
+}
+
+// migrateSchema runs every migration after version on raw and marks it as CurrentSchemaVersion.
+// It refuses a store from a newer version, because we do not know what we would lose.
+func migrateSchema(storePath string, raw map[string]interface{}) error {
+	version, err := rawSchemaVersion(raw)
+	if err != nil {
+		return err
+	}
+	if version > CurrentSchemaVersion {
+		return &StoreSchemaError{Path: storePath, Version: version}
+	}
+	for _, migration := range schemaMigrations {
+		if migration.Version <= version {
+			continue
+		}
+		if err := migration.Migrate(raw); err != nil {
+			return fmt.Errorf("migrating to schema version %d (%s): %w", migration.Version, migration.Describe, err)
+		}
+	}
+	key, _, _ := rawField(raw, schemaVersionKey)
+	raw[key] = CurrentSchemaVersion
+	return nil
+}
+
+// rawVideos is the Videos table of raw, one plain map per video
+func rawVideos(raw map[string]interface{}) (map[string]map[string]interface{}, error) {
+	videos := make(map[string]map[string]interface{})
+	_, value, ok := rawField(raw, "Videos")
+	if !ok || value == nil {
+		return videos, nil
+	}
+	table, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Videos is a %T, not a table", value)
+	}
+	for videoId, video := range table {
+		fields, ok := video.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("video %s is a %T, not a table", videoId, video)
+		}
+		videos[videoId] = fields
+	}
+	return videos, nil
+}
+
+// legacyVideoTypes are the numbers knownvideos.toml used when MT3VideoType was an enum
+var legacyVideoTypes = map[int64]MT3VideoType{0: Unknown, 1: Livestream, 2: Snippet}
+
+// migrateLegacyVideoTypes is schema version 1: VideoType = 1 becomes VideoType = "Livestream"
+func migrateLegacyVideoTypes(raw map[string]interface{}) error {
+	videos, err := rawVideos(raw)
+	if err != nil {
+		return err
+	}
+	for videoId, video := range videos {
+		key, value, ok := rawField(video, "VideoType")
+		if !ok {
+			continue
+		}
+		number, isNumber := rawInt(value)
+		if !isNumber {
+			continue
+		}
+		videoType, known := legacyVideoTypes[number]
+		if !known {
+			return fmt.Errorf("video %s: unknown video type number %d", videoId, number)
+		}
+		video[key] = string(videoType)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package mt3
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fixtures in testdata/knownvideos, one per schema version and format
+const fixtureDir = "../testdata/knownvideos"
+
+var currentFixtures = []string{"v0.toml", "v0.json", "v0.yaml", "v1.toml", "v1.json", "v1.yaml"}
+var newerFixtures = []string{"v2.toml", "v2.json", "v2.yaml"}
+
+func loadFixture(t *testing.T, name string) KnownVideos {
+	t.Helper()
+	knownVideos, err := OpenStore(filepath.Join(fixtureDir, name)).Load(context.Background())
+	if err != nil {
+		t.Fatalf("loading %s: %v", name, err)
+	}
+	return knownVideos
+}
+
+func TestLoadEverySchemaVersion(t *testing.T) {
+	type want struct {
+		videoType MT3VideoType
+		duration  time.Duration
+	}
+	livestream := want{Livestream, 90 * time.Minute}
+	snippet := want{Snippet, 95 * time.Second}
+	untitled := want{Unknown, 0}
+	tests := []struct {
+		fixture string
+		videos  map[string]want
+	}{
+		{"v0.toml", map[string]want{"mt3video001": livestream, "mt3video002": snippet, "mt3video003": untitled}},
+		{"v0.json", map[string]want{"mt3video001": livestream, "mt3video002": snippet}},
+		{"v0.yaml", map[string]want{"mt3video001": livestream, "mt3video002": snippet}},
+		{"v1.toml", map[string]want{"mt3video001": livestream, "mt3video002": snippet, "mt3video003": untitled}},
+		{"v1.json", map[string]want{"mt3video001": livestream, "mt3video002": snippet, "mt3video003": untitled}},
+		{"v1.yaml", map[string]want{"mt3video001": livestream, "mt3video002": snippet, "mt3video003": untitled}},
+	}
+	for _, test := range tests {
+		knownVideos := loadFixture(t, test.fixture)
+		if len(knownVideos.Videos) != len(test.videos) {
+			t.Errorf("%s has %d videos, want %d", test.fixture, len(knownVideos.Videos), len(test.videos))
+		}
+		for videoId, want := range test.videos {
+			video, ok := knownVideos.Videos[videoId]
+			if !ok {
+				t.Errorf("%s is missing %s", test.fixture, videoId)
+				continue
+			}
+			if video.VideoType != want.videoType || video.Duration != want.duration {
+				t.Errorf("%s %s = %s %v, want %s %v", test.fixture, videoId, video.VideoType, video.Duration, want.videoType, want.duration)
+			}
+			if video.VideoId != videoId {
+				t.Errorf("%s %s has VideoId %q", test.fixture, videoId, video.VideoId)
+			}
+		}
+	}
+
+	// The v1 fixtures were all written from the same catalog, overrides and all
+	fromTOML := loadFixture(t, "v1.toml")
+	for _, fixture := range []string{"v1.json", "v1.yaml"} {
+		if err := sameKnownVideos(fromTOML, loadFixture(t, fixture)); err != nil {
+			t.Errorf("%s does not match v1.toml: %v", fixture, err)
+		}
+	}
+	if override := fromTOML.Overrides["mt3video003"]; !override.Exclude || override.Notes != "deleted by mistake" {
+		t.Errorf("v1.toml override = %+v", override)
+	}
+}
+
+func TestLoadNewerSchemaVersion(t *testing.T) {
+	for _, fixture := range newerFixtures {
+		_, err := OpenStore(filepath.Join(fixtureDir, fixture)).Load(context.Background())
+		var schemaErr *StoreSchemaError
+		if !errors.As(err, &schemaErr) {
+			t.Errorf("loading %s: got %v, want a *StoreSchemaError", fixture, err)
+			continue
+		}
+		if schemaErr.Version != 2 {
+			t.Errorf("loading %s: version %d, want 2", fixture, schemaErr.Version)
+		}
+	}
+}
+
+// Every fixture, once migrated, saves and loads back the same in every kind of store,
+// and a second save of the same catalog writes exactly the same file
+func TestMigratedStoreRoundTrips(t *testing.T) {
+	ctx := context.Background()
+	for _, fixture := range currentFixtures {
+		knownVideos := loadFixture(t, fixture)
+		for _, extension := range []string{".toml", ".json", ".yaml", ".db"} {
+			store := OpenStore(filepath.Join(t.TempDir(), "knownvideos"+extension))
+			if err := store.Save(ctx, knownVideos, SkipBackup); err != nil {
+				t.Fatalf("%s to %s: %v", fixture, extension, err)
+			}
+			first, _ := os.ReadFile(store.Path())
+			loaded, err := store.Load(ctx)
+			if err != nil {
+				t.Fatalf("%s to %s: reading back: %v", fixture, extension, err)
+			}
+			if err := sameKnownVideos(knownVideos, loaded); err != nil {
+				t.Errorf("%s to %s lost something: %v", fixture, extension, err)
+			}
+			if extension == ".db" {
+				continue
+			}
+			// unset times and availability are left out, as they are in TOML and YAML
+			if strings.Contains(string(first), "0001-01-01") || strings.Contains(string(first), `"Availability": ""`) {
+				t.Errorf("%s to %s wrote unset fields:\n%s", fixture, extension, first)
+			}
+			if err := store.Save(ctx, loaded, SkipBackup); err != nil {
+				t.Fatal(err)
+			}
+			if second, _ := os.ReadFile(store.Path()); string(second) != string(first) {
+				t.Errorf("%s to %s: saving what was loaded changed the file\n%s\nthen\n%s", fixture, extension, first, second)
+			}
+		}
+	}
+}
+
+// SQLite keeps its schema version in PRAGMA user_version rather than in the data
+func TestSQLiteSchemaVersion(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "knownvideos.db")
+	store := OpenStore(path)
+	if err := store.Save(ctx, loadFixture(t, "v1.toml"), SkipBackup); err != nil {
+		t.Fatal(err)
+	}
+	setVersion := func(version int) {
+		db, err := sql.Open("sqlite", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		if _, err := db.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, version)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	setVersion(0)
+	if _, err := store.Load(ctx); err != nil {
+		t.Fatalf("loading a version 0 database: %v", err)
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var version int
+	err = db.QueryRow(`PRAGMA user_version`).Scan(&version)
+	db.Close()
+	if err != nil || version != CurrentSchemaVersion {
+		t.Errorf("user_version after loading = %d, %v; want %d", version, err, CurrentSchemaVersion)
+	}
+
+	setVersion(2)
+	var schemaErr *StoreSchemaError
+	if _, err := store.Load(ctx); !errors.As(err, &schemaErr) || schemaErr.Version != 2 {
+		t.Errorf("loading a version 2 database: got %v, want a *StoreSchemaError", err)
+	}
+}
+
+// MigrateStore wraps what went wrong, so callers can still tell a newer store or an interrupted one
+func TestMigrateStoreWrapsErrors(t *testing.T) {
+	to := OpenStore(filepath.Join(t.TempDir(), "knownvideos.db"))
+	_, err := MigrateStore(context.Background(), OpenStore(filepath.Join(fixtureDir, "v2.toml")), to, SkipBackup)
+	var schemaErr *StoreSchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Errorf("migrating a newer store: got %v, want a *StoreSchemaError", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = MigrateStore(ctx, OpenStore(filepath.Join(fixtureDir, "v1.toml")), to, SkipBackup)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("migrating with a cancelled ctx: got %v, want context.Canceled", err)
+	}
+}
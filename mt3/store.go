@@ -0,0 +1,116 @@
+// Package mt3 keeps a local catalog of the videos on the Marble Track 3 YouTube channel
+// (knownvideos.toml) and fills it in from the YouTube Data API.
+// The commands in the repository root are thin wrappers around it.
+package mt3
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Hugo will do different things with different types of videos
+// These are the built in types; a rules file (see LoadClassifier) can name any others it likes
+type MT3VideoType string
+
+const (
+	Unknown    MT3VideoType = "Unknown"
+	Livestream MT3VideoType = "Livestream"
+	Snippet    MT3VideoType = "Snippet"
+)
+
+// UnmarshalText reads a type name.  The old enum's numbers are turned into names by
+// migrateLegacyVideoTypes before we get here, so a number means something is wrong.
+func (videoType *MT3VideoType) UnmarshalText(text []byte) error {
+	name := strings.TrimSpace(string(text))
+	if _, err := strconv.Atoi(name); err == nil {
+		return fmt.Errorf("unknown video type number %s", name)
+	}
+	if name == "" {
+		name = string(Unknown)
+	}
+	*videoType = MT3VideoType(name)
+	return nil
+}
+
+func (videoType MT3VideoType) String() string {
+	if videoType == "" {
+		return string(Unknown)
+	}
+	return string(videoType)
+}
+
+// This is the structure to be used in the knownvideos.toml file (see ResolveStorePath)
+// Videos is what YouTube told us; Overrides is what I told it (see VideoOverride) and sync never touches it
+type KnownVideos struct {
+	Videos    map[string]VideoMeta
+	Overrides map[string]VideoOverride `toml:",omitempty"`
+}
+
+// SortedVideoIds returns the IDs of the videos keep likes (every video if keep is nil), oldest first, then by ID.
+// Map order is random, so everything that walks the catalog uses this to do it the same way every run:
+// the one-file stores write videos in this order so a git diff only shows the ones that changed,
+// and the videos.list batches come out the same from run to run.
+func (knownVideos KnownVideos) SortedVideoIds(keep func(VideoMeta) bool) []string {
+	videoIds := make([]string, 0, len(knownVideos.Videos))
+	for videoId, video := range knownVideos.Videos {
+		if keep == nil || keep(video) {
+			videoIds = append(videoIds, videoId)
+		}
+	}
+	sort.Slice(videoIds, func(i, j int) bool {
+		a, b := knownVideos.Videos[videoIds[i]], knownVideos.Videos[videoIds[j]]
+		if !a.Published.Equal(b.Published) {
+			return a.Published.Before(b.Published)
+		}
+		return videoIds[i] < videoIds[j]
+	})
+	return videoIds
+}
+
+// Each video will have basic data.
+// Duration will allow me to report just how long I have spent on Marble Track 3
+type VideoMeta struct {
+	VideoId     string
+	Title       string
+	Published   time.Time // requires `import time`
+	Duration    time.Duration
+	VideoType   MT3VideoType
+	Tags        []string `yaml:",omitempty" json:",omitempty"` // from the video's snippet, used as Hugo tags
+	Description string
+	// From liveStreamingDetails, which only live broadcasts (and premieres) have.
+	// LiveChecked is false until the durations fetch has asked, so the type falls back to the title until then
+	LiveChecked        bool
+	WasLive            bool      // it was (or will be) broadcast live
+	LiveActualStart    time.Time `toml:",omitempty" yaml:",omitempty" json:",omitzero"`
+	LiveActualEnd      time.Time `toml:",omitempty" yaml:",omitempty" json:",omitzero"`
+	LiveScheduledStart time.Time `toml:",omitempty" yaml:",omitempty" json:",omitzero"`
+	// Whether YouTube still shows it (see VideoAvailability), and when we first noticed it did not
+	Availability        VideoAvailability `toml:",omitempty" yaml:",omitempty" json:",omitzero"`
+	AvailabilityNoticed time.Time         `toml:",omitempty" yaml:",omitempty" json:",omitzero"`
+}
+
+// a TOML list of videos is stored locally to reduce the number of times we have to contact Youtube API
+// This loads the file and returns as a struct of type KnownVideos
+// A missing file is a fresh start, but a file we cannot parse is a *StoreCorruptError (see RepairKnownVideos)
+// and one from a newer schema is a *StoreSchemaError.  Older schemas are migrated (see schemaMigrations).
+func LoadLocalKnownVideos(ctx context.Context, storePath string) (KnownVideos, error) {
+	return loadStoreFile(ctx, storePath, func(data []byte, knownVideos *KnownVideos) error {
+		return decodeMigrated(storePath, data, knownVideos, toml.Unmarshal, marshalTOML)
+	})
+}
+
+// a TOML list of videos is stored locally to reduce the number of times we have to contact Youtube API
+// This saves the file, creating its directory if this is the first run
+// The old file is kept as a timestamped backup, and the new one is written to a temp file
+// and renamed into place so a crash mid-encode cannot truncate the catalog.
+// keepBackups is how many backups to keep; 0 keeps them all.
+// Videos are written in publish order (see encodeTOMLKnownVideos) so git diffs stay small.
+func SaveLocalKnownVideos(ctx context.Context, storePath string, knownVideos KnownVideos, keepBackups int) error {
+	return saveStoreFile(ctx, storePath, knownVideos, keepBackups, encodeTOMLKnownVideos)
+}
@@ -0,0 +1,189 @@
+package mt3
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Backups sit next to the store and look like knownvideos.toml.20190412-153055.123.bak
+// The timestamp sorts correctly as a string, so the newest backup is last.
+const backupTimeFormat = "20060102-150405.000"
+const backupSuffix = ".bak"
+
+// writeFileAtomically never lets path be half written.
+// write() fills a temp file in the same directory (so rename stays on one filesystem),
+// the temp file is fsynced and only then renamed over path.
+// If anything fails, path still has its old contents.
+func writeFileAtomically(path string, write func(w io.Writer) error) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	// Clean up the temp file unless we successfully renamed it
+	renamed := false
+	defer func() {
+		if !renamed {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	if err := write(tmp); err != nil {
+		return fmt.Errorf("writing %s: %w", tmpName, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// CreateTemp uses 0600; the store has always been 0644 via os.Create
+	if err := os.Chmod(tmpName, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+	renamed = true
+	return syncDir(dir)
+}
+
+// syncDir makes the rename itself durable.  Some platforms cannot fsync a directory,
+// which is fine; the rename has still happened.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	d.Sync()
+	return nil
+}
+
+// backupPath is the name of a backup of storePath taken at time t
+func backupPath(storePath string, t time.Time) string {
+	return storePath + "." + t.Format(backupTimeFormat) + backupSuffix
+}
+
+// ListBackups returns the backups of storePath, oldest first
+func ListBackups(storePath string) ([]string, error) {
+	matches, err := filepath.Glob(storePath + ".*" + backupSuffix)
+	if err != nil {
+		return nil, err
+	}
+	var backups []string
+	for _, match := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(match, storePath+"."), backupSuffix)
+		if _, err := time.Parse(backupTimeFormat, stamp); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// SkipBackup as keepBackups saves without making a backup, for the checkpoints a long run saves
+// part way through, which would otherwise push every real backup out
+const SkipBackup = -1
+
+// BackupKnownVideos copies the current store to a timestamped backup
+// and then deletes all but the newest keep backups.
+// Nothing to back up on the very first run, or if keep is SkipBackup.
+func BackupKnownVideos(storePath string, keep int) error {
+	if keep == SkipBackup {
+		return nil
+	}
+	if err := copyToBackup(storePath); err != nil {
+		return err
+	}
+	return pruneBackups(storePath, keep)
+}
+
+// copyToBackup copies the current store to a backup named for right now
+func copyToBackup(storePath string) error {
+	src, err := os.Open(storePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return writeFileAtomically(backupPath(storePath, time.Now()), func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+}
+
+// pruneBackups keeps the newest keep backups.  keep < 1 keeps everything.
+func pruneBackups(storePath string, keep int) error {
+	if keep < 1 {
+		return nil
+	}
+	backups, err := ListBackups(storePath)
+	if err != nil {
+		return err
+	}
+	for len(backups) > keep {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// findBackup turns what the user asked to restore into a backup file.
+// "latest" is the newest backup; otherwise the timestamp (20190412-153055.123) or the full file name.
+func findBackup(storePath string, which string) (string, error) {
+	backups, err := ListBackups(storePath)
+	if err != nil {
+		return "", err
+	}
+	if len(backups) == 0 {
+		return "", fmt.Errorf("there are no backups of %s", storePath)
+	}
+	if which == "latest" {
+		return backups[len(backups)-1], nil
+	}
+	for _, backup := range backups {
+		if backup == which || filepath.Base(backup) == which || strings.Contains(filepath.Base(backup), "."+which+backupSuffix) {
+			return backup, nil
+		}
+	}
+	return "", fmt.Errorf("no backup of %s matches %q (see the backups command)", storePath, which)
+}
+
+// RestoreKnownVideos rolls the store back to a backup.
+// The store we are replacing is backed up first, so a restore can itself be undone.
+func RestoreKnownVideos(storePath string, which string, keep int) (string, error) {
+	backup, err := findBackup(storePath, which)
+	if err != nil {
+		return "", err
+	}
+	src, err := os.Open(backup)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	if err := copyToBackup(storePath); err != nil {
+		return "", err
+	}
+	err = writeFileAtomically(storePath, func(w io.Writer) error {
+		_, err := io.Copy(w, src)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return backup, pruneBackups(storePath, keep)
+}
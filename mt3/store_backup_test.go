@@ -0,0 +1,156 @@
+package mt3
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var backupTime = time.Date(2019, 4, 12, 15, 30, 55, 123000000, time.Local)
+
+// storeWithBackups writes current as the store and one backup per entry of older, a day apart starting at backupTime
+func storeWithBackups(t *testing.T, current string, older ...string) string {
+	t.Helper()
+	storePath := filepath.Join(t.TempDir(), "knownvideos.toml")
+	writeTestFile(t, storePath, current)
+	for i, content := range older {
+		writeTestFile(t, backupPath(storePath, backupTime.AddDate(0, 0, i)), content)
+	}
+	return storePath
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readTestFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestBackupKnownVideos(t *testing.T) {
+	storePath := storeWithBackups(t, "current", "one", "two", "three", "four")
+	if err := BackupKnownVideos(storePath, 3); err != nil {
+		t.Fatal(err)
+	}
+	backups, err := ListBackups(storePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 3 {
+		t.Fatalf("backups = %q, want the newest 3", backups)
+	}
+	var contents []string
+	for _, backup := range backups {
+		contents = append(contents, readTestFile(t, backup))
+	}
+	if got := strings.Join(contents, ","); got != "three,four,current" {
+		t.Errorf("backups hold %s, want the two newest old ones and a copy of the store", got)
+	}
+
+	if err := BackupKnownVideos(storePath, SkipBackup); err != nil {
+		t.Fatal(err)
+	}
+	if after, _ := ListBackups(storePath); len(after) != 3 {
+		t.Errorf("SkipBackup made a backup: %q", after)
+	}
+
+	// the first run has nothing to back up
+	firstRun := filepath.Join(t.TempDir(), "knownvideos.toml")
+	if err := BackupKnownVideos(firstRun, 3); err != nil {
+		t.Fatal(err)
+	}
+	if backups, _ := ListBackups(firstRun); len(backups) != 0 {
+		t.Errorf("backups of a store that does not exist yet: %q", backups)
+	}
+}
+
+func TestFindBackup(t *testing.T) {
+	storePath := storeWithBackups(t, "current", "one", "two")
+	first := backupPath(storePath, backupTime)
+	second := backupPath(storePath, backupTime.AddDate(0, 0, 1))
+	tests := []struct {
+		which string
+		want  string
+	}{
+		{"latest", second},
+		{"20190412-153055.123", first},
+		{filepath.Base(first), first},
+		{second, second},
+	}
+	for _, test := range tests {
+		got, err := findBackup(storePath, test.which)
+		if err != nil || got != test.want {
+			t.Errorf("findBackup(%q) = %s, %v; want %s", test.which, got, err, test.want)
+		}
+	}
+	if _, err := findBackup(storePath, "20200101-000000.000"); err == nil {
+		t.Error("found a backup that does not exist")
+	}
+	if _, err := findBackup(storeWithBackups(t, "current"), "latest"); err == nil {
+		t.Error("found the latest backup when there are none")
+	}
+}
+
+func TestRestoreKnownVideos(t *testing.T) {
+	storePath := storeWithBackups(t, "current", "one", "two")
+	restored, err := RestoreKnownVideos(storePath, "20190412-153055.123", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored != backupPath(storePath, backupTime) {
+		t.Errorf("restored %s", restored)
+	}
+	if got := readTestFile(t, storePath); got != "one" {
+		t.Errorf("store holds %q after the restore, want \"one\"", got)
+	}
+	// the store that was replaced is the newest backup, so the restore can be undone
+	backups, _ := ListBackups(storePath)
+	if len(backups) != 3 || readTestFile(t, backups[2]) != "current" {
+		t.Errorf("backups = %q, want the replaced store kept as the newest", backups)
+	}
+
+	if _, err := RestoreKnownVideos(storePath, "no such backup", 0); err == nil {
+		t.Error("restoring a backup that does not exist worked")
+	}
+	if got := readTestFile(t, storePath); got != "one" {
+		t.Errorf("a failed restore changed the store to %q", got)
+	}
+}
+
+func TestWriteFileAtomicallyFailure(t *testing.T) {
+	storePath := storeWithBackups(t, "current")
+	broken := errors.New("disk full")
+	err := writeFileAtomically(storePath, func(w io.Writer) error {
+		io.WriteString(w, "half of the ")
+		return broken
+	})
+	if !errors.Is(err, broken) {
+		t.Errorf("got %v, want the write error", err)
+	}
+	if got := readTestFile(t, storePath); got != "current" {
+		t.Errorf("store holds %q after a failed write, want it untouched", got)
+	}
+	entries, err := os.ReadDir(filepath.Dir(storePath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Errorf("directory holds %q, want only the store and no temp file", names)
+	}
+}
@@ -0,0 +1,259 @@
+package mt3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// loadStoreFile reads a one-file store with decode.
+// A missing file is a fresh start, but one decode chokes on is a *StoreCorruptError,
+// unless it is a *StoreSchemaError, which is passed on as it is.
+// The file is read in one go, so ctx is only checked before starting.
+func loadStoreFile(ctx context.Context, storePath string, decode func(data []byte, knownVideos *KnownVideos) error) (KnownVideos, error) {
+	var knownVideos KnownVideos
+	if err := ctx.Err(); err != nil {
+		return knownVideos, err
+	}
+
+	data, err := os.ReadFile(storePath)
+	if os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "No known videos yet at %s so we will start from scratch\r\n", storePath) // stderr, so report --format csv or json stays clean
+		return knownVideos, nil
+	}
+	if err != nil {
+		return knownVideos, err
+	}
+	if err := decode(data, &knownVideos); err != nil {
+		var schemaErr *StoreSchemaError
+		if errors.As(err, &schemaErr) {
+			return knownVideos, err
+		}
+		return knownVideos, newStoreCorruptError(storePath, err)
+	}
+	return knownVideos, nil
+}
+
+// decodeMigrated decodes data into knownVideos with unmarshal, bringing an older schema up to date first.
+// That means decoding it into plain maps for migrateSchema and marshalling the result back into the
+// same format, so durations, times and video types are decoded just as they would be from a current store.
+func decodeMigrated(storePath string, data []byte, knownVideos *KnownVideos, unmarshal func(data []byte, v interface{}) error, marshal func(v interface{}) ([]byte, error)) error {
+	raw := make(map[string]interface{})
+	if err := unmarshal(data, &raw); err != nil {
+		return err
+	}
+	version, err := rawSchemaVersion(raw)
+	if err != nil {
+		return err
+	}
+	if version != CurrentSchemaVersion {
+		if err := migrateSchema(storePath, raw); err != nil {
+			return err
+		}
+		if data, err = marshal(raw); err != nil {
+			return fmt.Errorf("re-encoding after migrating from schema version %d: %w", version, err)
+		}
+	}
+	return unmarshal(data, knownVideos)
+}
+
+func marshalTOML(v interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	err := toml.NewEncoder(buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+// unmarshalJSON keeps numbers as json.Number, so nanosecond durations survive a migration exactly
+func unmarshalJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// saveStoreFile backs up storePath and atomically replaces it with what encode writes.
+// Like loadStoreFile it only checks ctx before starting; the rename means a save is never half done.
+func saveStoreFile(ctx context.Context, storePath string, knownVideos KnownVideos, keepBackups int, encode func(w io.Writer, knownVideos KnownVideos) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(storePath), 0755); err != nil {
+		return err
+	}
+	if err := BackupKnownVideos(storePath, keepBackups); err != nil {
+		return fmt.Errorf("backing up %s: %w", storePath, err)
+	}
+	err := writeFileAtomically(storePath, func(w io.Writer) error {
+		return encode(w, knownVideos)
+	})
+	if err != nil {
+		return fmt.Errorf("saving %s: %w", storePath, err)
+	}
+	return nil
+}
+
+// bareTOMLKey is what a TOML key can be without quotes; YouTube IDs always are
+var bareTOMLKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func tomlKey(key string) string {
+	if bareTOMLKey.MatchString(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+// encodeTOMLKnownVideos writes the same tables the TOML encoder would (see videoTableHeader),
+// but with the videos in SortedVideoIds order instead of the encoder's order of map keys
+func encodeTOMLKnownVideos(w io.Writer, knownVideos KnownVideos) error {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "%s = %d\n\n[Videos]\n", schemaVersionKey, CurrentSchemaVersion)
+	for _, videoId := range knownVideos.SortedVideoIds(nil) {
+		fmt.Fprintf(buf, "\n  [Videos.%s]\n", tomlKey(videoId))
+		video := new(bytes.Buffer)
+		if err := toml.NewEncoder(video).Encode(knownVideos.Videos[videoId]); err != nil {
+			return fmt.Errorf("video %s: %w", videoId, err)
+		}
+		for _, line := range bytes.SplitAfter(video.Bytes(), []byte("\n")) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				buf.WriteString("    ")
+			}
+			buf.Write(line)
+		}
+	}
+	if len(knownVideos.Overrides) > 0 {
+		buf.WriteString("\n")
+		overrides := struct{ Overrides map[string]VideoOverride }{knownVideos.Overrides}
+		if err := toml.NewEncoder(buf).Encode(overrides); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// JSONStore is knownvideos.json: the same catalog as TOMLStore, for people who would rather read JSON
+type JSONStore struct {
+	path string
+}
+
+func (store *JSONStore) Load(ctx context.Context) (KnownVideos, error) {
+	return loadStoreFile(ctx, store.path, func(data []byte, knownVideos *KnownVideos) error {
+		return decodeMigrated(store.path, data, knownVideos, unmarshalJSON, json.Marshal)
+	})
+}
+
+func (store *JSONStore) Save(ctx context.Context, knownVideos KnownVideos, keepBackups int) error {
+	return saveStoreFile(ctx, store.path, knownVideos, keepBackups, encodeJSONKnownVideos)
+}
+
+func (store *JSONStore) Path() string {
+	return store.path
+}
+
+// encodeJSONKnownVideos writes the Videos object by hand, because encoding/json would sort it by ID
+func encodeJSONKnownVideos(w io.Writer, knownVideos KnownVideos) error {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "{\n  \"%s\": %d,\n  \"Videos\": {", schemaVersionKey, CurrentSchemaVersion)
+	videoIds := knownVideos.SortedVideoIds(nil)
+	for i, videoId := range videoIds {
+		key, err := json.Marshal(videoId)
+		if err != nil {
+			return err
+		}
+		video, err := json.MarshalIndent(knownVideos.Videos[videoId], "    ", "  ")
+		if err != nil {
+			return fmt.Errorf("video %s: %w", videoId, err)
+		}
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		fmt.Fprintf(buf, "\n    %s: %s", key, video)
+	}
+	if len(videoIds) > 0 {
+		buf.WriteString("\n  ")
+	}
+	buf.WriteString("}")
+	if len(knownVideos.Overrides) > 0 {
+		overrides, err := json.MarshalIndent(knownVideos.Overrides, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, ",\n  \"Overrides\": %s", overrides)
+	}
+	buf.WriteString("\n}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// YAMLStore is knownvideos.yaml (or .yml).  yaml.v3 lowercases the field names, e.g. videoid and published.
+type YAMLStore struct {
+	path string
+}
+
+func (store *YAMLStore) Load(ctx context.Context) (KnownVideos, error) {
+	return loadStoreFile(ctx, store.path, func(data []byte, knownVideos *KnownVideos) error {
+		return decodeMigrated(store.path, data, knownVideos, yaml.Unmarshal, yaml.Marshal)
+	})
+}
+
+func (store *YAMLStore) Save(ctx context.Context, knownVideos KnownVideos, keepBackups int) error {
+	return saveStoreFile(ctx, store.path, knownVideos, keepBackups, encodeYAMLKnownVideos)
+}
+
+func (store *YAMLStore) Path() string {
+	return store.path
+}
+
+// yamlNode encodes value on its own so it can go into a hand built mapping
+func yamlNode(value interface{}) (*yaml.Node, error) {
+	node := new(yaml.Node)
+	err := node.Encode(value)
+	return node, err
+}
+
+// encodeYAMLKnownVideos builds the videos mapping node by node, because yaml.v3 would sort it by ID
+func encodeYAMLKnownVideos(w io.Writer, knownVideos KnownVideos) error {
+	videos := &yaml.Node{Kind: yaml.MappingNode}
+	for _, videoId := range knownVideos.SortedVideoIds(nil) {
+		key, err := yamlNode(videoId)
+		if err != nil {
+			return err
+		}
+		video, err := yamlNode(knownVideos.Videos[videoId])
+		if err != nil {
+			return fmt.Errorf("video %s: %w", videoId, err)
+		}
+		videos.Content = append(videos.Content, key, video)
+	}
+
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	versionKey, _ := yamlNode(strings.ToLower(schemaVersionKey))
+	version, _ := yamlNode(CurrentSchemaVersion)
+	videosKey, _ := yamlNode("videos")
+	root.Content = append(root.Content, versionKey, version, videosKey, videos)
+	if len(knownVideos.Overrides) > 0 {
+		overridesKey, _ := yamlNode("overrides")
+		overrides, err := yamlNode(knownVideos.Overrides)
+		if err != nil {
+			return err
+		}
+		root.Content = append(root.Content, overridesKey, overrides)
+	}
+
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
@@ -0,0 +1,136 @@
+package mt3
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// StoreCorruptError means knownvideos.toml exists but could not be decoded.
+// We must not carry on with an empty catalog in that case, because saving
+// would overwrite every video we know about.
+type StoreCorruptError struct {
+	Path   string
+	Line   int // 0 if the TOML library could not tell us
+	Column int
+	Err    error
+}
+
+func (e *StoreCorruptError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s is corrupt at line %d, column %d: %v", e.Path, e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("%s is corrupt: %v", e.Path, e.Err)
+}
+
+func (e *StoreCorruptError) Unwrap() error {
+	return e.Err
+}
+
+// newStoreCorruptError pulls the position out of a TOML parse error if there is one
+func newStoreCorruptError(path string, err error) *StoreCorruptError {
+	corrupt := &StoreCorruptError{Path: path, Err: err}
+	var parseErr toml.ParseError
+	if errors.As(err, &parseErr) {
+		corrupt.Line = parseErr.Position.Line
+		corrupt.Column = parseErr.Position.Col
+		corrupt.Err = errors.New(parseErr.Message)
+	}
+	return corrupt
+}
+
+// The encoder writes each video, and each override, as its own table, like
+//
+//	[Videos]
+//	  [Videos.dQw4w9WgXcQ]
+//	    VideoId = "dQw4w9WgXcQ"
+//	[Overrides]
+//	  [Overrides.dQw4w9WgXcQ]
+//	    Exclude = true
+//
+// so a broken file can be cut up at each [Videos.xxx] and [Overrides.xxx] header and the pieces decoded one at a time.
+var pieceTableHeader = regexp.MustCompile(`^\s*\[\s*(Videos|Overrides)\.(.+?)\s*\]\s*$`)
+
+// Any other table header, like [Overrides] itself, ends the piece before it
+var otherTableHeader = regexp.MustCompile(`^\s*\[[^\[\]=]+\]\s*$`)
+
+// RepairReport says what RepairKnownVideos managed to save
+type RepairReport struct {
+	Salvaged          int
+	SalvagedOverrides int
+	Lost              []string // table headers of the videos and overrides we could not decode, with the reason
+}
+
+// RepairKnownVideos decodes every video and override table in storePath that it can and skips the rest.
+// It does not write anything; the caller decides whether to save the result.
+func RepairKnownVideos(storePath string) (KnownVideos, RepairReport, error) {
+	knownVideos := KnownVideos{Videos: make(map[string]VideoMeta), Overrides: make(map[string]VideoOverride)}
+	var report RepairReport
+
+	f, err := os.Open(storePath)
+	if err != nil {
+		return knownVideos, report, err
+	}
+	defer f.Close()
+
+	var sections [][]string // each one starts with its [Videos.xxx] or [Overrides.xxx] header line
+	var startLines []int
+	inPiece := false
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if pieceTableHeader.MatchString(line) {
+			sections = append(sections, nil)
+			startLines = append(startLines, lineNumber)
+			inPiece = true
+		} else if otherTableHeader.MatchString(line) {
+			inPiece = false
+		}
+		// Anything outside a video or override table ([Videos] and [Overrides] themselves) has nothing to salvage
+		if inPiece {
+			sections[len(sections)-1] = append(sections[len(sections)-1], line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return knownVideos, report, err
+	}
+
+	for i, section := range sections {
+		isOverride := pieceTableHeader.FindStringSubmatch(section[0])[1] == "Overrides"
+		var piece KnownVideos
+		// A piece has no SchemaVersion, so it is migrated as if it were from before there was one
+		err := decodeMigrated(storePath, []byte(strings.Join(section, "\n")), &piece, toml.Unmarshal, marshalTOML)
+		if err == nil && isOverride && (len(piece.Overrides) != 1 || len(piece.Videos) != 0) {
+			err = errors.New("table does not hold exactly one override")
+		}
+		if err == nil && !isOverride && (len(piece.Videos) != 1 || len(piece.Overrides) != 0) {
+			err = errors.New("table does not hold exactly one video")
+		}
+		if err != nil {
+			report.Lost = append(report.Lost, fmt.Sprintf("line %d %s: %v", startLines[i], strings.TrimSpace(section[0]), err))
+			continue
+		}
+		for id, override := range piece.Overrides {
+			knownVideos.Overrides[id] = override
+			report.SalvagedOverrides++
+		}
+		for id, video := range piece.Videos {
+			if video.VideoId == "" {
+				video.VideoId = id
+			}
+			knownVideos.Videos[id] = video
+			report.Salvaged++
+		}
+	}
+	if len(knownVideos.Overrides) == 0 {
+		knownVideos.Overrides = nil
+	}
+	return knownVideos, report, nil
+}
@@ -0,0 +1,65 @@
+package mt3
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// brokenStore writes v1.toml with old replaced by new, so that it no longer parses
+func brokenStore(t *testing.T, old string, new string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(fixtureDir, "v1.toml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), old) {
+		t.Fatalf("v1.toml has no %q", old)
+	}
+	path := filepath.Join(t.TempDir(), "knownvideos.toml")
+	if err := os.WriteFile(path, []byte(strings.Replace(string(data), old, new, 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRepairKeepsOverrides(t *testing.T) {
+	path := brokenStore(t, `Title = "Marble Track 3 snippet: the first spiral"`, `Title = "Marble Track 3 snippet: the first spiral`)
+	knownVideos, report, err := RepairKnownVideos(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Salvaged != 2 || report.SalvagedOverrides != 1 || len(report.Lost) != 1 {
+		t.Fatalf("report = %+v, want 2 videos and 1 override salvaged, 1 lost", report)
+	}
+	if !strings.Contains(report.Lost[0], "[Videos.mt3video002]") {
+		t.Errorf("lost %q, want mt3video002", report.Lost[0])
+	}
+	if _, ok := knownVideos.Videos["mt3video003"]; !ok {
+		t.Error("mt3video003, the last video, was not salvaged")
+	}
+	if override := knownVideos.Overrides["mt3video003"]; !override.Exclude || override.Notes != "deleted by mistake" {
+		t.Errorf("override = %+v, want the one from v1.toml", override)
+	}
+}
+
+func TestRepairBrokenOverride(t *testing.T) {
+	path := brokenStore(t, `Exclude = true`, `Exclude = yes`)
+	knownVideos, report, err := RepairKnownVideos(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Salvaged != 3 || report.SalvagedOverrides != 0 || len(report.Lost) != 1 {
+		t.Fatalf("report = %+v, want 3 videos salvaged and the override lost", report)
+	}
+	if !strings.Contains(report.Lost[0], "[Overrides.mt3video003]") {
+		t.Errorf("lost %q, want the mt3video003 override", report.Lost[0])
+	}
+	if _, ok := knownVideos.Videos["mt3video003"]; !ok {
+		t.Error("a broken override took the last video with it")
+	}
+	if knownVideos.Overrides != nil {
+		t.Errorf("overrides = %+v, want none", knownVideos.Overrides)
+	}
+}
@@ -0,0 +1,258 @@
+package mt3
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	_ "modernc.org/sqlite" // pure Go, so no cgo needed to build
+)
+
+// SQLiteStore keeps one row per video, so a save only writes the videos that changed
+type SQLiteStore struct {
+	path string
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS videos (
+	video_id TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	published TEXT NOT NULL,
+	duration_ns INTEGER NOT NULL,
+	video_type TEXT NOT NULL,
+	tags TEXT NOT NULL,
+	description TEXT NOT NULL,
+	live_checked INTEGER NOT NULL,
+	was_live INTEGER NOT NULL,
+	live_actual_start TEXT NOT NULL,
+	live_actual_end TEXT NOT NULL,
+	live_scheduled_start TEXT NOT NULL,
+	availability TEXT NOT NULL,
+	availability_noticed TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS overrides (
+	video_id TEXT PRIMARY KEY,
+	title TEXT NOT NULL,
+	video_type TEXT NOT NULL,
+	exclude INTEGER NOT NULL,
+	notes TEXT NOT NULL
+);
+`
+
+func (store *SQLiteStore) Path() string {
+	return store.path
+}
+
+// open opens the database and makes sure the tables are there.
+// The schema version lives in PRAGMA user_version.  The tables have not changed since SQLite
+// stores were added at schema version 1, so an older database only needs the version set;
+// a newer one is a *StoreSchemaError.
+func (store *SQLiteStore) open(ctx context.Context) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", store.path)
+	if err != nil {
+		return nil, err
+	}
+	var version int
+	if err := db.QueryRowContext(ctx, `PRAGMA user_version`).Scan(&version); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("reading the schema version of %s: %w", store.path, err)
+	}
+	if version > CurrentSchemaVersion {
+		db.Close()
+		return nil, &StoreSchemaError{Path: store.path, Version: version}
+	}
+	if _, err := db.ExecContext(ctx, sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating tables in %s: %w", store.path, err)
+	}
+	if version < CurrentSchemaVersion {
+		if _, err := db.ExecContext(ctx, fmt.Sprintf(`PRAGMA user_version = %d`, CurrentSchemaVersion)); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("setting the schema version of %s: %w", store.path, err)
+		}
+	}
+	return db, nil
+}
+
+// sqlTime stores times as RFC 3339 with nanoseconds and the original offset, so nothing is lost; zero is ""
+func sqlTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+func parseSQLTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+// queryer is what loadFrom needs, so it works on a *sql.DB or inside a *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (store *SQLiteStore) Load(ctx context.Context) (KnownVideos, error) {
+	var knownVideos KnownVideos
+	if _, err := os.Stat(store.path); os.IsNotExist(err) {
+		fmt.Fprintf(os.Stderr, "No known videos yet at %s so we will start from scratch\r\n", store.path)
+		return knownVideos, nil
+	}
+	db, err := store.open(ctx)
+	if err != nil {
+		return knownVideos, err
+	}
+	defer db.Close()
+	return store.loadFrom(ctx, db)
+}
+
+func (store *SQLiteStore) loadFrom(ctx context.Context, db queryer) (KnownVideos, error) {
+	var knownVideos KnownVideos
+
+	rows, err := db.QueryContext(ctx, `SELECT video_id, title, published, duration_ns, video_type, tags, description,
+		live_checked, was_live, live_actual_start, live_actual_end, live_scheduled_start,
+		availability, availability_noticed FROM videos`)
+	if err != nil {
+		return knownVideos, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var video VideoMeta
+		var published, tags, liveActualStart, liveActualEnd, liveScheduledStart, availabilityNoticed string
+		var durationNs int64
+		err := rows.Scan(&video.VideoId, &video.Title, &published, &durationNs, &video.VideoType, &tags, &video.Description,
+			&video.LiveChecked, &video.WasLive, &liveActualStart, &liveActualEnd, &liveScheduledStart,
+			&video.Availability, &availabilityNoticed)
+		if err != nil {
+			return knownVideos, err
+		}
+		video.Duration = time.Duration(durationNs)
+		if err := json.Unmarshal([]byte(tags), &video.Tags); err != nil {
+			return knownVideos, fmt.Errorf("video %s: tags: %w", video.VideoId, err)
+		}
+		times := []struct {
+			into *time.Time
+			from string
+		}{
+			{&video.Published, published},
+			{&video.LiveActualStart, liveActualStart},
+			{&video.LiveActualEnd, liveActualEnd},
+			{&video.LiveScheduledStart, liveScheduledStart},
+			{&video.AvailabilityNoticed, availabilityNoticed},
+		}
+		for _, t := range times {
+			if *t.into, err = parseSQLTime(t.from); err != nil {
+				return knownVideos, fmt.Errorf("video %s: %w", video.VideoId, err)
+			}
+		}
+		if knownVideos.Videos == nil {
+			knownVideos.Videos = make(map[string]VideoMeta)
+		}
+		knownVideos.Videos[video.VideoId] = video
+	}
+	if err := rows.Err(); err != nil {
+		return knownVideos, err
+	}
+
+	overrideRows, err := db.QueryContext(ctx, `SELECT video_id, title, video_type, exclude, notes FROM overrides`)
+	if err != nil {
+		return knownVideos, err
+	}
+	defer overrideRows.Close()
+	for overrideRows.Next() {
+		var videoId string
+		var override VideoOverride
+		if err := overrideRows.Scan(&videoId, &override.Title, &override.VideoType, &override.Exclude, &override.Notes); err != nil {
+			return knownVideos, err
+		}
+		if knownVideos.Overrides == nil {
+			knownVideos.Overrides = make(map[string]VideoOverride)
+		}
+		knownVideos.Overrides[videoId] = override
+	}
+	return knownVideos, overrideRows.Err()
+}
+
+// Save writes only the rows that differ from what is in the database, in one transaction
+func (store *SQLiteStore) Save(ctx context.Context, knownVideos KnownVideos, keepBackups int) error {
+	if err := os.MkdirAll(filepath.Dir(store.path), 0755); err != nil {
+		return err
+	}
+	if err := BackupKnownVideos(store.path, keepBackups); err != nil {
+		return fmt.Errorf("backing up %s: %w", store.path, err)
+	}
+	db, err := store.open(ctx)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := store.saveIn(ctx, tx, knownVideos); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("saving to %s: %w", store.path, err)
+	}
+	return tx.Commit()
+}
+
+func (store *SQLiteStore) saveIn(ctx context.Context, tx *sql.Tx, knownVideos KnownVideos) error {
+	existing, err := store.loadFrom(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	for videoId, video := range knownVideos.Videos {
+		if old, ok := existing.Videos[videoId]; ok && reflect.DeepEqual(old, video) {
+			continue
+		}
+		tags, err := json.Marshal(video.Tags)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO videos (video_id, title, published, duration_ns, video_type, tags, description,
+			live_checked, was_live, live_actual_start, live_actual_end, live_scheduled_start,
+			availability, availability_noticed) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			videoId, video.Title, sqlTime(video.Published), int64(video.Duration), string(video.VideoType), string(tags), video.Description,
+			video.LiveChecked, video.WasLive, sqlTime(video.LiveActualStart), sqlTime(video.LiveActualEnd), sqlTime(video.LiveScheduledStart),
+			string(video.Availability), sqlTime(video.AvailabilityNoticed))
+		if err != nil {
+			return fmt.Errorf("video %s: %w", videoId, err)
+		}
+	}
+	for videoId := range existing.Videos {
+		if _, ok := knownVideos.Videos[videoId]; !ok {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM videos WHERE video_id = ?`, videoId); err != nil {
+				return err
+			}
+		}
+	}
+
+	for videoId, override := range knownVideos.Overrides {
+		if old, ok := existing.Overrides[videoId]; ok && old == override {
+			continue
+		}
+		_, err := tx.ExecContext(ctx, `INSERT OR REPLACE INTO overrides (video_id, title, video_type, exclude, notes) VALUES (?, ?, ?, ?, ?)`,
+			videoId, override.Title, string(override.VideoType), override.Exclude, override.Notes)
+		if err != nil {
+			return fmt.Errorf("override for %s: %w", videoId, err)
+		}
+	}
+	for videoId := range existing.Overrides {
+		if _, ok := knownVideos.Overrides[videoId]; !ok {
+			if _, err := tx.ExecContext(ctx, `DELETE FROM overrides WHERE video_id = ?`, videoId); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package mt3
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+)
+
+// Store is somewhere KnownVideos are kept between runs.
+// OpenStore picks the implementation from the file name.
+// A cancelled ctx stops a Load or Save with ctx.Err(), leaving the store as it was.
+type Store interface {
+	// Load returns everything in the store.  A store that does not exist yet is empty, not an error.
+	Load(ctx context.Context) (KnownVideos, error)
+	// Save replaces what is in the store with knownVideos, keeping keepBackups backups (0 keeps them all)
+	Save(ctx context.Context, knownVideos KnownVideos, keepBackups int) error
+	// Path is the file the store lives in
+	Path() string
+}
+
+// sqliteExtensions are the file names OpenStore treats as SQLite databases
+var sqliteExtensions = map[string]bool{".db": true, ".sqlite": true, ".sqlite3": true}
+
+// OpenStore returns the Store for storePath: SQLite for .db, .sqlite and .sqlite3 files,
+// JSON for .json, YAML for .yaml and .yml, and TOML otherwise
+func OpenStore(storePath string) Store {
+	extension := strings.ToLower(filepath.Ext(storePath))
+	switch {
+	case sqliteExtensions[extension]:
+		return &SQLiteStore{path: storePath}
+	case extension == ".json":
+		return &JSONStore{path: storePath}
+	case extension == ".yaml" || extension == ".yml":
+		return &YAMLStore{path: storePath}
+	}
+	return &TOMLStore{path: storePath}
+}
+
+// TOMLStore is the original knownvideos.toml: the whole catalog in one file, rewritten on every save
+type TOMLStore struct {
+	path string
+}
+
+func (store *TOMLStore) Load(ctx context.Context) (KnownVideos, error) {
+	return LoadLocalKnownVideos(ctx, store.path)
+}
+
+func (store *TOMLStore) Save(ctx context.Context, knownVideos KnownVideos, keepBackups int) error {
+	return SaveLocalKnownVideos(ctx, store.path, knownVideos, keepBackups)
+}
+
+func (store *TOMLStore) Path() string {
+	return store.path
+}
@@ -0,0 +1,458 @@
+package mt3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings" // needed to create a string of video IDs, separated by commas
+	"time"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/youtube/v3"
+)
+
+// What AddNewVideosToList did with one playlist item
+type SyncOutcome uint8
+
+const (
+	VideoUnchanged SyncOutcome = iota
+	VideoAdded
+	VideoUpdated
+)
+
+// Counts printed at the end of a sync
+type SyncSummary struct {
+	Added       int
+	Updated     int
+	Unchanged   int
+	Unavailable int // known videos newly noticed to be private or removed
+	Skipped     int // playlist items we could not make sense of, e.g. a bad publish time
+}
+
+func (summary *SyncSummary) count(outcome SyncOutcome) {
+	switch outcome {
+	case VideoAdded:
+		summary.Added++
+	case VideoUpdated:
+		summary.Updated++
+	default:
+		summary.Unchanged++
+	}
+}
+
+func (summary *SyncSummary) add(other SyncSummary) {
+	summary.Added += other.Added
+	summary.Updated += other.Updated
+	summary.Unchanged += other.Unchanged
+	summary.Unavailable += other.Unavailable
+	summary.Skipped += other.Skipped
+}
+
+func (summary SyncSummary) String() string {
+	text := fmt.Sprintf("%d added, %d updated, %d unchanged, %d newly private or removed", summary.Added, summary.Updated, summary.Unchanged, summary.Unavailable)
+	if summary.Skipped > 0 {
+		text += fmt.Sprintf(", %d skipped", summary.Skipped)
+	}
+	return text
+}
+
+// A full sync walks every page of the uploads playlist.
+// An incremental sync stops once a whole page is older than the newest video we already knew about.
+// The playlist is only roughly sorted by publish date (see PlaylistItemsList), so
+// incrementalOverlap keeps going a bit past that point to catch stragglers.
+const incrementalOverlap = 7 * 24 * time.Hour
+
+// newestPublished is the publish time of the most recent video in knownVideos
+func newestPublished(knownVideos *KnownVideos) time.Time {
+	var newest time.Time
+	for _, video := range knownVideos.Videos {
+		if video.Published.After(newest) {
+			newest = video.Published
+		}
+	}
+	return newest
+}
+
+// knownVideos is the list of videos in our local TOML file
+// playlistItem is one of the myriad videos in my channel
+// This looks at each video ID to see if we need to add it to knownVideos,
+// or update the title and publish date of one we already have
+// New videos get a VideoType from classifier, using the little we know from the playlist
+// An item we cannot read is an error and knownVideos is left alone, so the caller can skip it and carry on
+func AddNewVideosToList(playlistItem *youtube.PlaylistItem, knownVideos *KnownVideos, classifier *Classifier) (SyncOutcome, error) {
+	// Thanks to https://github.com/go-shadow/moment/blob/master/moment.go for the format that must be used
+	// https://golang.org/src/time/format.go?s=37668:37714#L735
+	vidPublishTime, err := time.Parse("2006-01-02T15:04:05Z0700", playlistItem.ContentDetails.VideoPublishedAt)
+	if err != nil {
+		return VideoUnchanged, fmt.Errorf("video %s: publish time %q: %w", playlistItem.Snippet.ResourceId.VideoId, playlistItem.ContentDetails.VideoPublishedAt, err)
+	}
+	var vidDuration time.Duration // TODO put actual number here if they ever make this data available https://issuetracker.google.com/issues/35170788
+
+	// See if the video key we loaded from Youtube's API is already known to us
+	video, exists := knownVideos.Videos[playlistItem.Snippet.ResourceId.VideoId]
+	// Save video information into knownVideos only if it does not exist
+	//    (if it exists, we would overwrite the duration with 0)
+	availability := playlistItemAvailability(playlistItem, video.Availability)
+	if !exists {
+		video = VideoMeta{
+			VideoId:     playlistItem.Snippet.ResourceId.VideoId,
+			Title:       playlistItem.Snippet.Title,
+			Published:   vidPublishTime,
+			Duration:    vidDuration,
+			Description: playlistItem.Snippet.Description,
+		}
+		video.VideoType, _ = classifier.Classify(video)
+		setAvailability(&video, availability)
+		knownVideos.Videos[video.VideoId] = video
+		return VideoAdded, nil
+	}
+
+	// Known video, but the title may have been edited since, or the publish date
+	// may have changed (e.g. a premiere that has now happened)
+	if video.Title == playlistItem.Snippet.Title && video.Published.Equal(vidPublishTime) && video.Availability == availability {
+		return VideoUnchanged, nil
+	}
+	video.Title = playlistItem.Snippet.Title
+	video.Published = vidPublishTime
+	setAvailability(&video, availability)
+	knownVideos.Videos[video.VideoId] = video
+	return VideoUpdated, nil
+}
+
+// playlistItemAvailability is what the status part of the uploads playlist says about a video.
+// Private uploads are still listed for their owner; anything listed at all has not been removed.
+// Without a status part we keep what we had, unless that was removed.
+func playlistItemAvailability(playlistItem *youtube.PlaylistItem, known VideoAvailability) VideoAvailability {
+	if playlistItem.Status == nil {
+		if known == VideoRemoved {
+			return VideoAvailable
+		}
+		return known
+	}
+	if playlistItem.Status.PrivacyStatus == "private" {
+		return VideoPrivate
+	}
+	return VideoAvailable
+}
+
+// SyncOptions are how LoadNewVideosFromMyChannel walks the uploads playlist
+type SyncOptions struct {
+	FullSync bool        // walk every page; otherwise we stop once pages are older than what we already know
+	Resume   *SyncResume // carry on from where an unfinished sync got to, if it was walking the same playlist
+	// Checkpoint is called after every page with where to carry on from, so the caller can save
+	// knownVideos and the SyncResume.  PageToken is "" once there is nothing left to resume.
+	Checkpoint func(resume SyncResume) error
+}
+
+// Download from Youtube all the videos in my channel
+// so we can look for new ones that do not exist in local TOML file
+// Running out of quota just ends the walk early.  Any other API error is returned along with the
+// summary so far, and knownVideos keeps every page we got through.  So does cancelling ctx, which
+// stops the page in flight and returns an error wrapping ctx.Err().
+func LoadNewVideosFromMyChannel(ctx context.Context, api YouTubeAPI, knownVideos *KnownVideos, options SyncOptions, classifier *Classifier) (SyncSummary, error) {
+
+	// VideoMeta data does not exist if there is no local data in knownvideos.toml
+	if knownVideos.Videos == nil {
+		knownVideos.Videos = make(map[string]VideoMeta)
+	}
+
+	// A resumed sync finishes the way it started
+	fullSync := options.FullSync
+	started := time.Now()
+	if options.Resume != nil {
+		fullSync = fullSync || options.Resume.FullSync
+		started = options.Resume.Started
+	}
+
+	// Anything published before this is assumed to be known already (incremental only).
+	// A resumed sync keeps the one it started with: the pages it already saved are the newest uploads,
+	// so working it out again from knownVideos would stop on the first page of the ones it never got to.
+	var stopBefore time.Time
+	if !fullSync {
+		if options.Resume != nil {
+			stopBefore = options.Resume.StopBefore
+		} else if newest := newestPublished(knownVideos); !newest.IsZero() {
+			stopBefore = newest.Add(-incrementalOverlap)
+		}
+	}
+	if stopBefore.IsZero() {
+		fmt.Println("Full sync: checking every page of uploads")
+	} else {
+		fmt.Printf("Incremental sync: stopping after a page of videos published before %s\r\n", stopBefore.Format("2006-01-02"))
+	}
+
+	var summary SyncSummary
+	seen := make(map[string]bool) // every video in the uploads playlist, to spot removed ones after a full sync
+	completed := true             // whether we walked every page
+	response, err := api.ChannelsListMine(ctx, "contentDetails")
+	if err != nil {
+		return summary, fmt.Errorf("finding my channel: %w", err)
+	}
+
+	for _, channel := range response.Items {
+		playlistId := channel.ContentDetails.RelatedPlaylists.Uploads
+
+		// Print the playlist ID for the list of uploaded videos.
+		fmt.Printf("Checking for new videos in list %s\r\n", playlistId)
+
+		nextPageToken := ""
+		resumedPageToken := "" // what we carried on from, in case YouTube no longer takes it
+		if options.Resume != nil && options.Resume.PlaylistId == playlistId && options.Resume.PageToken != "" {
+			nextPageToken = options.Resume.PageToken
+			resumedPageToken = nextPageToken
+			completed = false // the pages before it were seen by another run, so we cannot spot removed videos
+			fmt.Printf("Resuming the sync started %s from page %s\r\n", started.Local().Format("2006-01-02 15:04"), nextPageToken)
+		}
+		var numItemsPerPage int64 = 50 // max 50 https://developers.google.com/youtube/v3/docs/playlistItems/list#parameters
+		for {
+			// Retrieve next set of items in the playlist.
+			// Items are not returned in perfectly sorted order, so the incremental rule looks at the whole page
+			playlistResponse, err := api.PlaylistItemsList(ctx, "snippet,ContentDetails,status", playlistId, nextPageToken, numItemsPerPage)
+			if IsAPIError(err, APIErrorQuotaExceeded) {
+				// Keep the pages we already have rather than losing them with the whole run
+				fmt.Printf("Out of quota, stopping before uploads page %s: %v\r\n", nextPageToken, err)
+				completed = false
+				break
+			}
+			if resumedPageToken != "" && nextPageToken == resumedPageToken && rejectedPageToken(err) {
+				// Otherwise every sync would fail the same way until someone thought of --restart
+				fmt.Printf("YouTube no longer takes page %s (%v), starting again from the first page\r\n", nextPageToken, err)
+				nextPageToken, resumedPageToken = "", ""
+				if options.Checkpoint != nil {
+					if err := options.Checkpoint(SyncResume{PlaylistId: playlistId, FullSync: fullSync, Started: started, StopBefore: stopBefore}); err != nil {
+						return summary, fmt.Errorf("forgetting the rejected page: %w", err)
+					}
+				}
+				continue
+			}
+			if err != nil {
+				return summary, fmt.Errorf("listing uploads page %q of playlist %s: %w", nextPageToken, playlistId, err)
+			}
+
+			var pageSummary SyncSummary
+			pageIsOld := true // every item on the page was published before stopBefore
+			for _, playlistItem := range playlistResponse.Items {
+				videoId := playlistItem.Snippet.ResourceId.VideoId
+				seen[videoId] = true
+				before, known := knownVideos.Videos[videoId]
+				wasAvailable := known && before.Availability == VideoAvailable
+				outcome, err := AddNewVideosToList(playlistItem, knownVideos, classifier)
+				if err != nil {
+					fmt.Printf("Skipping %v\r\n", err)
+					pageSummary.Skipped++
+					continue
+				}
+				pageSummary.count(outcome)
+				if wasAvailable && knownVideos.Videos[videoId].Availability != VideoAvailable {
+					pageSummary.Unavailable++
+				}
+				if !knownVideos.Videos[playlistItem.Snippet.ResourceId.VideoId].Published.Before(stopBefore) {
+					pageIsOld = false
+				}
+			}
+			fmt.Printf("Page of %d videos: %v\r\n", len(playlistResponse.Items), pageSummary)
+			summary.add(pageSummary)
+
+			// Set the token to retrieve the next page of results
+			// or exit the loop if all results have been retrieved.
+			nextPageToken = playlistResponse.NextPageToken
+			stopping := !fullSync && pageIsOld
+			if options.Checkpoint != nil {
+				resume := SyncResume{PlaylistId: playlistId, PageToken: nextPageToken, FullSync: fullSync, Started: started, StopBefore: stopBefore}
+				if stopping {
+					resume.PageToken = ""
+				}
+				if err := options.Checkpoint(resume); err != nil {
+					return summary, fmt.Errorf("saving progress after uploads page %q: %w", nextPageToken, err)
+				}
+			}
+			if nextPageToken == "" {
+				break
+			}
+			if stopping {
+				fmt.Println("Everything on that page is older than what we already had.  Use --full to check every page.")
+				completed = false
+				break
+			}
+		}
+	}
+
+	// Only a walk of every page can tell that a video is gone from the playlist
+	if completed && len(response.Items) > 0 {
+		for videoId, video := range knownVideos.Videos {
+			if seen[videoId] || video.Availability == VideoRemoved {
+				continue
+			}
+			fmt.Printf("Video %s is no longer in my uploads, marking it removed\r\n", videoId)
+			setAvailability(&video, VideoRemoved)
+			knownVideos.Videos[videoId] = video
+			summary.Unavailable++
+		}
+	}
+	return summary, nil
+}
+
+// rejectedPageToken says whether YouTube refused playlistItems.list because of its page token.
+// Page tokens expire, so the one an interrupted sync saved may be no good by the time we resume.
+// Nothing else about the request changes from page to page, so a 400 can only mean the token.
+func rejectedPageToken(err error) bool {
+	var googleErr *googleapi.Error
+	return errors.As(err, &googleErr) && googleErr.Code == http.StatusBadRequest
+}
+
+// returns the IDs of every known video without a Duration, oldest first
+// The IDs will be sent to YouTube API to get the video Durations
+func videosWithEmptyDuration(knownVideos *KnownVideos) []string {
+	// look through all the known videos to find those without Duration
+	// so we can load the duration from Youtube API in this lovely separate call
+	return knownVideos.SortedVideoIds(func(video VideoMeta) bool {
+		return video.Duration == 0 && video.Availability != VideoRemoved // we can still see our own private videos
+	})
+}
+
+// chunkVideoIDs splits videoIDs into comma separated strings of at most size IDs each
+func chunkVideoIDs(videoIDs []string, size int) []string {
+	var chunks []string
+	for len(videoIDs) > size {
+		chunks = append(chunks, strings.Join(videoIDs[:size], ","))
+		videoIDs = videoIDs[size:]
+	}
+	if len(videoIDs) > 0 {
+		chunks = append(chunks, strings.Join(videoIDs, ","))
+	}
+	return chunks
+}
+
+// This fills in every video without a Duration, 50 at a time.  50 is the limit on how many videoIDs can be sent to get their metadata
+// Also get video title, which I should have changed soon after finishing the live stream
+// Now that we know everything about them, classifier gets another go at each video's type
+// Running out of quota stops early without an error; the rest are fetched next time
+// checkpoint, if not nil, is called after every batch so the caller can save what we have so far
+// Cancelling ctx stops the batch in flight; the error wraps ctx.Err() and the earlier batches are kept
+func FillInDurations(ctx context.Context, api YouTubeAPI, knownVideos *KnownVideos, classifier *Classifier, checkpoint func() error) error {
+
+	emptyDurationIDs := videosWithEmptyDuration(knownVideos)
+	batches := chunkVideoIDs(emptyDurationIDs, MaxIdsPerVideosList)
+	fmt.Printf("%d videos need a duration, fetching in %d batches\r\n", len(emptyDurationIDs), len(batches))
+
+	filled := 0
+	for batchNumber, videoIDs := range batches {
+		batchFilled, err := fillInDurationsBatch(ctx, api, knownVideos, videoIDs, classifier)
+		if IsAPIError(err, APIErrorQuotaExceeded) {
+			fmt.Printf("Out of quota after %d of %d batches, the rest will be fetched next time: %v\r\n", batchNumber, len(batches), err)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("getting durations for batch %d/%d starting with video %s: %w", batchNumber+1, len(batches), strings.SplitN(videoIDs, ",", 2)[0], err)
+		}
+		filled += batchFilled
+		fmt.Printf("Batch %d/%d done, %d of %d durations filled in\r\n", batchNumber+1, len(batches), filled, len(emptyDurationIDs))
+		if checkpoint != nil {
+			if err := checkpoint(); err != nil {
+				return fmt.Errorf("saving progress after durations batch %d/%d: %w", batchNumber+1, len(batches), err)
+			}
+		}
+	}
+	return nil
+}
+
+// videoDetailsParts is everything applyVideoDetails reads from videos.list
+const videoDetailsParts = "snippet,contentDetails,liveStreamingDetails,status"
+
+// fillInDurationsBatch asks for up to 50 comma separated videoIDs in one call
+// and returns how many of them now have a Duration.  Nothing is changed if the call fails.
+func fillInDurationsBatch(ctx context.Context, api YouTubeAPI, knownVideos *KnownVideos, videoIDs string, classifier *Classifier) (int, error) {
+	// Call async function to load the metadata for these video IDs
+	response, err := api.VideosListMultipleIds(ctx, videoDetailsParts, videoIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	filled := 0
+	returned := make(map[string]bool)
+	for _, item := range response.Items {
+		returned[item.Id] = true
+		// https://stackoverflow.com/a/17443950/194309
+		// I wanted to do this     knownVideos.Videos[item.Id].Duration = item.ContentDetails.Duration
+		// but that gives an error.   Have to do this
+		vid := knownVideos.Videos[item.Id]
+		if err := applyVideoDetails(&vid, item, classifier); err != nil {
+			fmt.Printf("Skipping video %s: %v\r\n", item.Id, err)
+			continue
+		}
+		knownVideos.Videos[item.Id] = vid
+		if vid.Duration != 0 {
+			filled++
+		}
+	}
+
+	// YouTube leaves out deleted videos, and private ones that are not ours
+	for _, videoId := range strings.Split(videoIDs, ",") {
+		if vid, known := knownVideos.Videos[videoId]; known && !returned[videoId] {
+			fmt.Printf("Video %s did not come back from YouTube, marking it removed\r\n", videoId)
+			setAvailability(&vid, VideoRemoved)
+			knownVideos.Videos[videoId] = vid
+		}
+	}
+	return filled, nil
+}
+
+// applyVideoDetails copies what videos.list said about item into vid and classifies it again.
+// vid is left alone if the duration cannot be read.
+func applyVideoDetails(vid *VideoMeta, item *youtube.Video, classifier *Classifier) error {
+	if item.ContentDetails == nil || item.Snippet == nil {
+		return fmt.Errorf("videos.list left out snippet or contentDetails")
+	}
+	// Google returns an ISO 8601 duration like PT1H45M41S, or P1DT2H3M for really long streams
+	vidDuration, err := ParseISO8601Duration(item.ContentDetails.Duration)
+	if err != nil {
+		return err
+	}
+	// P0D means YouTube does not know the length yet (premieres, upcoming streams)
+	// Leaving Duration at 0 means we will ask again next time
+	if vidDuration == 0 {
+		fmt.Printf("Video %s has no duration yet (%s)\r\n", item.Id, item.ContentDetails.Duration)
+	}
+
+	vid.Duration = vidDuration
+	vid.Title = item.Snippet.Title
+	vid.Tags = item.Snippet.Tags
+	vid.Description = item.Snippet.Description
+	recordLiveStreamingDetails(vid, item)
+	recordAvailability(vid, item)
+	vid.VideoType, _ = classifier.Classify(*vid)
+	return nil
+}
+
+// recordLiveStreamingDetails copies what YouTube knows about item being broadcast live into video.
+// Titles get edited after a stream ends, so this is what tells us it was a livestream.
+func recordLiveStreamingDetails(video *VideoMeta, item *youtube.Video) {
+	video.LiveChecked = true
+	details := item.LiveStreamingDetails
+	broadcastContent := ""
+	if item.Snippet != nil {
+		broadcastContent = item.Snippet.LiveBroadcastContent // "live", "upcoming" or "none"
+	}
+	video.WasLive = details != nil || broadcastContent == "live" || broadcastContent == "upcoming"
+	if details == nil {
+		video.LiveActualStart, video.LiveActualEnd, video.LiveScheduledStart = time.Time{}, time.Time{}, time.Time{}
+		return
+	}
+	video.LiveActualStart = parseLiveTime(video.VideoId, "actualStartTime", details.ActualStartTime)
+	video.LiveActualEnd = parseLiveTime(video.VideoId, "actualEndTime", details.ActualEndTime)
+	video.LiveScheduledStart = parseLiveTime(video.VideoId, "scheduledStartTime", details.ScheduledStartTime)
+}
+
+// parseLiveTime reads one RFC 3339 time from liveStreamingDetails.  Missing or garbled times are left zero.
+func parseLiveTime(videoId string, field string, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		fmt.Printf("Ignoring %s of video %s: %v\r\n", field, videoId, err)
+		return time.Time{}
+	}
+	return parsed.UTC()
+}
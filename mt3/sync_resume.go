@@ -0,0 +1,57 @@
+package mt3
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SyncResume is how far an unfinished sync got through the uploads playlist,
+// so the next sync can carry on from there instead of starting again
+type SyncResume struct {
+	PlaylistId string    `json:"playlist_id"`
+	PageToken  string    `json:"page_token"` // the next page to fetch
+	FullSync   bool      `json:"full_sync"`
+	Started    time.Time `json:"started"` // when the interrupted sync began
+	// StopBefore is where the interrupted incremental sync was going to stop.  It has to be kept, because
+	// the pages it did fetch make the store look newer than it was; zero walks every remaining page.
+	StopBefore time.Time `json:"stop_before,omitzero"`
+}
+
+// SyncResumePath is where the sync of storePath keeps its SyncResume: knownvideos.toml gets knownvideos.sync.json
+func SyncResumePath(storePath string) string {
+	return strings.TrimSuffix(storePath, filepath.Ext(storePath)) + ".sync.json"
+}
+
+// LoadSyncResume reads path.  No file means the last sync finished, and is nil without an error.
+func LoadSyncResume(path string) (*SyncResume, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var resume SyncResume
+	if err := json.Unmarshal(data, &resume); err != nil {
+		return nil, err
+	}
+	return &resume, nil
+}
+
+// SaveSyncResume records resume in path, or removes path once there is nothing left to resume
+func SaveSyncResume(path string, resume SyncResume) error {
+	if resume.PageToken == "" {
+		err := os.Remove(path)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return writeFileAtomically(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(resume)
+	})
+}
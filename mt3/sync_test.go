@@ -0,0 +1,335 @@
+package mt3
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/api/youtube/v3"
+)
+
+var firstUpload = time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// fakeChannel has count uploads a day apart, vid000 first, listed newest first like the real uploads playlist
+func fakeChannel(count int) *FakeYouTube {
+	fake := NewFakeYouTube()
+	for i := count - 1; i >= 0; i-- {
+		fake.AddUpload(fakeVideoId(i), fmt.Sprintf("Video %d", i), firstUpload.AddDate(0, 0, i), fmt.Sprintf("PT%dM", i+1))
+	}
+	return fake
+}
+
+func fakeVideoId(i int) string {
+	return fmt.Sprintf("vid%03d", i)
+}
+
+// callsTo is the arguments of every call fake got to method, e.g. ["snippet UUfakechannel 50 50", ...]
+func callsTo(fake *FakeYouTube, method string) []string {
+	var calls []string
+	for _, call := range fake.Calls {
+		if strings.HasPrefix(call, method+" ") {
+			calls = append(calls, strings.TrimPrefix(call, method+" "))
+		}
+	}
+	return calls
+}
+
+// pageTokens is the page token of every playlistItems.list call fake got
+func pageTokens(fake *FakeYouTube) []string {
+	var tokens []string
+	for _, call := range callsTo(fake, "PlaylistItemsList") {
+		fields := strings.Fields(call)
+		token := ""
+		if len(fields) == 4 {
+			token = fields[2]
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens
+}
+
+// syncedChannel is what a full sync of fakeChannel(count) leaves in the store
+func syncedChannel(t *testing.T, count int) KnownVideos {
+	t.Helper()
+	var knownVideos KnownVideos
+	if _, err := LoadNewVideosFromMyChannel(context.Background(), fakeChannel(count), &knownVideos, SyncOptions{FullSync: true}, DefaultClassifier()); err != nil {
+		t.Fatal(err)
+	}
+	return knownVideos
+}
+
+func TestLoadNewVideosFullSync(t *testing.T) {
+	fake := fakeChannel(120)
+	var knownVideos KnownVideos
+	var checkpoints []string
+	options := SyncOptions{
+		FullSync: true,
+		Checkpoint: func(resume SyncResume) error {
+			checkpoints = append(checkpoints, resume.PageToken)
+			return nil
+		},
+	}
+	summary, err := LoadNewVideosFromMyChannel(context.Background(), fake, &knownVideos, options, DefaultClassifier())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Added != 120 || summary.Updated != 0 || summary.Unchanged != 0 {
+		t.Errorf("summary = %v, want 120 added", summary)
+	}
+	if got := strings.Join(pageTokens(fake), ","); got != ",50,100" {
+		t.Errorf("page tokens = %q, want three pages of 50", got)
+	}
+	if got := strings.Join(checkpoints, ","); got != "50,100," {
+		t.Errorf("checkpoints = %q, want one after every page, the last with no token", got)
+	}
+	video := knownVideos.Videos["vid007"]
+	if video.Title != "Video 7" || !video.Published.Equal(firstUpload.AddDate(0, 0, 7)) || video.Duration != 0 {
+		t.Errorf("vid007 = %+v", video)
+	}
+
+	// Another full sync finds nothing new
+	summary, err = LoadNewVideosFromMyChannel(context.Background(), fakeChannel(120), &knownVideos, SyncOptions{FullSync: true}, DefaultClassifier())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Added != 0 || summary.Unchanged != 120 {
+		t.Errorf("second sync summary = %v, want 120 unchanged", summary)
+	}
+}
+
+func TestLoadNewVideosIncremental(t *testing.T) {
+	knownVideos := syncedChannel(t, 120)
+
+	// Three new uploads land on the first page, so the second page is the first one that is all old
+	fake := fakeChannel(123)
+	summary, err := LoadNewVideosFromMyChannel(context.Background(), fake, &knownVideos, SyncOptions{}, DefaultClassifier())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Added != 3 || summary.Unchanged != 97 {
+		t.Errorf("summary = %v, want 3 added and 97 unchanged", summary)
+	}
+	if got := strings.Join(pageTokens(fake), ","); got != ",50" {
+		t.Errorf("page tokens = %q, want to stop after the second page", got)
+	}
+	for i := 120; i < 123; i++ {
+		if _, ok := knownVideos.Videos[fakeVideoId(i)]; !ok {
+			t.Errorf("%s was not added", fakeVideoId(i))
+		}
+	}
+
+	// Nothing new at all: the first page still has the week of overlap on it, so the second one is the old one
+	fake = fakeChannel(123)
+	summary, err = LoadNewVideosFromMyChannel(context.Background(), fake, &knownVideos, SyncOptions{}, DefaultClassifier())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Added != 0 {
+		t.Errorf("summary = %v, want nothing added", summary)
+	}
+	if got := strings.Join(pageTokens(fake), ","); got != ",50" {
+		t.Errorf("page tokens = %q, want to stop after the second page", got)
+	}
+}
+
+func TestLoadNewVideosResumedIncremental(t *testing.T) {
+	knownVideos := syncedChannel(t, 120)
+
+	// 200 new uploads are four pages; the first sync is interrupted after saving the first of them
+	ctx, cancel := context.WithCancel(context.Background())
+	var saved SyncResume
+	options := SyncOptions{
+		Checkpoint: func(resume SyncResume) error {
+			saved = resume
+			cancel()
+			return nil
+		},
+	}
+	_, err := LoadNewVideosFromMyChannel(ctx, fakeChannel(320), &knownVideos, options, DefaultClassifier())
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if saved.PageToken != "50" || !saved.StopBefore.Equal(firstUpload.AddDate(0, 0, 119-7)) {
+		t.Fatalf("saved resume = %+v", saved)
+	}
+
+	// The store now looks up to date, but the resumed sync must still reach the pages it never got to
+	fake := fakeChannel(320)
+	summary, err := LoadNewVideosFromMyChannel(context.Background(), fake, &knownVideos, SyncOptions{Resume: &saved}, DefaultClassifier())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Added != 150 {
+		t.Errorf("summary = %v, want the other 150 new videos added", summary)
+	}
+	if len(knownVideos.Videos) != 320 {
+		t.Errorf("%d known videos, want 320", len(knownVideos.Videos))
+	}
+	if got := pageTokens(fake); len(got) == 0 || got[0] != "50" {
+		t.Errorf("page tokens = %q, want to start from page 50", got)
+	}
+}
+
+func TestLoadNewVideosExpiredPageToken(t *testing.T) {
+	knownVideos := syncedChannel(t, 120)
+	fake := fakeChannel(123)
+	saved := SyncResume{PlaylistId: "UUfakechannel", PageToken: "expired", StopBefore: firstUpload.AddDate(0, 0, 119-7)}
+	var checkpoints []string
+	options := SyncOptions{
+		Resume: &saved,
+		Checkpoint: func(resume SyncResume) error {
+			checkpoints = append(checkpoints, resume.PageToken)
+			return nil
+		},
+	}
+	summary, err := LoadNewVideosFromMyChannel(context.Background(), fake, &knownVideos, options, DefaultClassifier())
+	if err != nil {
+		t.Fatalf("resuming from an expired page: %v", err)
+	}
+	if summary.Added != 3 {
+		t.Errorf("summary = %v, want 3 added", summary)
+	}
+	if got := strings.Join(pageTokens(fake), ","); got != "expired,,50" {
+		t.Errorf("page tokens = %q, want the expired page then the walk again from the start", got)
+	}
+	if len(checkpoints) == 0 || checkpoints[0] != "" {
+		t.Errorf("checkpoints = %q, want the expired page forgotten first", checkpoints)
+	}
+}
+
+func TestLoadNewVideosRemoved(t *testing.T) {
+	// vid000 is the oldest, so it is on the last page, which an incremental sync never reaches
+	knownVideos := syncedChannel(t, 120)
+	fake := fakeChannel(120)
+	fake.PlaylistItems = fake.PlaylistItems[:119]
+
+	if _, err := LoadNewVideosFromMyChannel(context.Background(), fake, &knownVideos, SyncOptions{}, DefaultClassifier()); err != nil {
+		t.Fatal(err)
+	}
+	if got := knownVideos.Videos["vid000"].Availability; got != VideoAvailable {
+		t.Errorf("incremental sync marked vid000 %q", got)
+	}
+
+	fake.Calls = nil
+	summary, err := LoadNewVideosFromMyChannel(context.Background(), fake, &knownVideos, SyncOptions{FullSync: true}, DefaultClassifier())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := knownVideos.Videos["vid000"]; got.Availability != VideoRemoved || got.AvailabilityNoticed.IsZero() {
+		t.Errorf("full sync left vid000 %q, noticed %v", got.Availability, got.AvailabilityNoticed)
+	}
+	if summary.Unavailable != 1 {
+		t.Errorf("summary = %v, want 1 newly removed", summary)
+	}
+}
+
+func TestLoadNewVideosOutOfQuota(t *testing.T) {
+	fake := fakeChannel(120)
+	fake.Errors["PlaylistItemsList"] = &APIError{Call: "playlistItems.list", Kind: APIErrorQuotaExceeded, Attempts: 1, Err: fmt.Errorf("quotaExceeded")}
+	var knownVideos KnownVideos
+	summary, err := LoadNewVideosFromMyChannel(context.Background(), fake, &knownVideos, SyncOptions{FullSync: true}, DefaultClassifier())
+	if err != nil {
+		t.Fatalf("running out of quota should just stop the sync, got %v", err)
+	}
+	if summary.Added != 0 || len(knownVideos.Videos) != 0 {
+		t.Errorf("summary = %v with %d videos, want nothing", summary, len(knownVideos.Videos))
+	}
+}
+
+func TestFillInDurations(t *testing.T) {
+	knownVideos := syncedChannel(t, 120)
+	fake := fakeChannel(120)
+	delete(fake.Videos, "vid010")
+	fake.Videos["vid020"].Status = &youtube.VideoStatus{PrivacyStatus: "private", UploadStatus: "processed"}
+	fake.Videos["vid030"].Status = &youtube.VideoStatus{PrivacyStatus: "public", UploadStatus: "rejected"}
+	fake.Videos["vid040"].LiveStreamingDetails = &youtube.VideoLiveStreamingDetails{ActualStartTime: "2019-02-10T12:00:00Z"}
+
+	checkpoints := 0
+	err := FillInDurations(context.Background(), fake, &knownVideos, DefaultClassifier(), func() error {
+		checkpoints++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var batchSizes []int
+	for _, call := range callsTo(fake, "VideosListMultipleIds") {
+		fields := strings.Fields(call)
+		batchSizes = append(batchSizes, len(strings.Split(fields[len(fields)-1], ",")))
+	}
+	if fmt.Sprint(batchSizes) != "[50 50 20]" {
+		t.Errorf("batch sizes = %v, want [50 50 20]", batchSizes)
+	}
+	if checkpoints != 3 {
+		t.Errorf("%d checkpoints, want one per batch", checkpoints)
+	}
+
+	for i := 0; i < 120; i++ {
+		video := knownVideos.Videos[fakeVideoId(i)]
+		if i == 10 {
+			continue
+		}
+		if want := time.Duration(i+1) * time.Minute; video.Duration != want {
+			t.Errorf("%s duration = %v, want %v", video.VideoId, video.Duration, want)
+		}
+	}
+	tests := []struct {
+		videoId string
+		want    VideoAvailability
+	}{
+		{"vid000", VideoAvailable},
+		{"vid010", VideoRemoved},
+		{"vid020", VideoPrivate},
+		{"vid030", VideoRemoved},
+	}
+	for _, test := range tests {
+		if got := knownVideos.Videos[test.videoId].Availability; got != test.want {
+			t.Errorf("%s availability = %q, want %q", test.videoId, got, test.want)
+		}
+	}
+	if video := knownVideos.Videos["vid040"]; !video.WasLive || video.VideoType != Livestream {
+		t.Errorf("vid040 = %+v, want a Livestream", video)
+	}
+
+	// vid010 is still missing a duration, but it is removed, so there is nothing left to ask for
+	fake.Calls = nil
+	if err := FillInDurations(context.Background(), fake, &knownVideos, DefaultClassifier(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := callsTo(fake, "VideosListMultipleIds"); len(got) != 0 {
+		t.Errorf("second run asked for %v, want nothing", got)
+	}
+}
+
+func TestFillInDurationsOutOfQuota(t *testing.T) {
+	knownVideos := syncedChannel(t, 120)
+	fake := fakeChannel(120)
+	fake.Errors["VideosListMultipleIds"] = &APIError{Call: "videos.list", Kind: APIErrorQuotaExceeded, Attempts: 1, Err: fmt.Errorf("quotaExceeded")}
+	if err := FillInDurations(context.Background(), fake, &knownVideos, DefaultClassifier(), nil); err != nil {
+		t.Fatalf("running out of quota should just stop, got %v", err)
+	}
+	if got := len(callsTo(fake, "VideosListMultipleIds")); got != 1 {
+		t.Errorf("%d calls after running out of quota, want 1", got)
+	}
+}
+
+func TestFillInDurationsCancelled(t *testing.T) {
+	knownVideos := syncedChannel(t, 120)
+	ctx, cancel := context.WithCancel(context.Background())
+	batches := 0
+	err := FillInDurations(ctx, fakeChannel(120), &knownVideos, DefaultClassifier(), func() error {
+		batches++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if batches != 1 || knownVideos.Videos["vid000"].Duration == 0 || knownVideos.Videos["vid050"].Duration != 0 {
+		t.Errorf("want just the first batch (the oldest 50 videos) filled in")
+	}
+}
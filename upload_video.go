@@ -1,61 +0,0 @@
-package main
-
-import (
-	"flag"
-	"fmt"
-	"log"
-	"os"
-	"strings"
-
-	"google.golang.org/api/youtube/v3"
-)
-
-var (
-	filename    = flag.String("filename", "", "Name of video file to upload")
-	title       = flag.String("title", "Test Title", "Video title")
-	description = flag.String("description", "Test Description", "Video description")
-	category    = flag.String("category", "22", "Video category")
-	keywords    = flag.String("keywords", "", "Comma separated list of video keywords")
-	privacy     = flag.String("privacy", "unlisted", "Video privacy status")
-)
-
-func main() {
-	flag.Parse()
-
-	if *filename == "" {
-		log.Fatalf("You must provide a filename of a video file to upload")
-	}
-
-	client := getClient(youtube.YoutubeUploadScope)
-
-	service, err := youtube.New(client)
-	if err != nil {
-		log.Fatalf("Error creating YouTube client: %v", err)
-	}
-
-	upload := &youtube.Video{
-		Snippet: &youtube.VideoSnippet{
-			Title:       *title,
-			Description: *description,
-			CategoryId:  *category,
-		},
-		Status: &youtube.VideoStatus{PrivacyStatus: *privacy},
-	}
-
-	// The API returns a 400 Bad Request response if tags is an empty string.
-	if strings.Trim(*keywords, "") != "" {
-		upload.Snippet.Tags = strings.Split(*keywords, ",")
-	}
-
-	call := service.Videos.Insert("snippet,status", upload)
-
-	file, err := os.Open(*filename)
-	defer file.Close()
-	if err != nil {
-		log.Fatalf("Error opening %v: %v", *filename, err)
-	}
-
-	response, err := call.Media(file).Do()
-	handleError(err, "")
-	fmt.Printf("Upload successful! Video ID: %v\n", response.Id)
-}